@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Adetunjii/go-grpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"time"
+)
+
+// login returns an access token for the user
+func login(conn *grpc.ClientConn, username string, password string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req := &pb.LoginRequest{Username: username, Password: password}
+	res, err := pb.NewAuthServiceClient(conn).Login(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("cannot log in as %s: %w", username, err)
+	}
+
+	return res.GetAccessToken(), nil
+}
+
+// authInterceptor attaches the access token to every call,
+// the server ignores it for the RPCs that everyone can access
+type authInterceptor struct {
+	accessToken string
+}
+
+func (interceptor *authInterceptor) unary() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(interceptor.attachToken(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+func (interceptor *authInterceptor) stream() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(interceptor.attachToken(ctx), desc, cc, method, opts...)
+	}
+}
+
+func (interceptor *authInterceptor) attachToken(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", interceptor.accessToken)
+}
@@ -4,15 +4,19 @@ import (
 	"bufio"
 	"context"
 	"flag"
+	"fmt"
 	"github.com/Adetunjii/go-grpc/pb"
 	"github.com/Adetunjii/go-grpc/sample"
+	"github.com/Adetunjii/go-grpc/serializer"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -37,13 +41,198 @@ func createLaptop(laptopClient pb.LaptopServiceClient, laptop *pb.Laptop) {
 	log.Printf("created laptop with id: %s", res.Id)
 }
 
-func searchLaptop(laptopClient pb.LaptopServiceClient, filter *pb.Filter) {
-	log.Print("search filter: ", filter)
+func createLaptops(laptopClient pb.LaptopServiceClient, laptops []*pb.Laptop) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := laptopClient.CreateLaptops(ctx)
+	if err != nil {
+		log.Fatal("cannot create laptops: ", err)
+	}
+
+	for _, laptop := range laptops {
+		err := stream.Send(&pb.CreateLaptopsRequest{Laptop: laptop})
+		if err != nil {
+			log.Fatal("cannot send laptop to server: ", err, stream.RecvMsg(nil))
+		}
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		log.Fatal("cannot receive response: ", err)
+	}
+
+	for _, result := range res.GetResults() {
+		if codes.Code(result.GetCode()) == codes.OK {
+			log.Printf("- [%d] created laptop with id: %s", result.GetIndex(), result.GetId())
+		} else {
+			log.Printf("- [%d] failed: %s (%s)", result.GetIndex(), result.GetMessage(), codes.Code(result.GetCode()))
+		}
+	}
+	log.Printf("created %d of %d laptops", res.GetCreatedCount(), len(laptops))
+}
+
+func getLaptop(laptopClient pb.LaptopServiceClient, laptopID string) {
+	req := &pb.GetLaptopRequest{Id: laptopID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := laptopClient.GetLaptop(ctx, req)
+	if err != nil {
+		st, ok := status.FromError(err)
+		if ok && st.Code() == codes.NotFound {
+			log.Printf("laptop %s not found", laptopID)
+		} else {
+			log.Fatal("cannot get laptop: ", err)
+		}
+		return
+	}
+
+	laptop := res.GetLaptop()
+	log.Print("- got: ", laptop.GetId())
+	log.Print("  + brand: ", laptop.GetBrand())
+	log.Print("  + name: ", laptop.GetName())
+	log.Print("  + price: ", laptop.GetPriceUsd())
+}
+
+func updateLaptop(laptopClient pb.LaptopServiceClient, laptop *pb.Laptop, expectedVersion uint64, paths ...string) {
+	req := &pb.UpdateLaptopRequest{
+		Laptop:          laptop,
+		UpdateMask:      &fieldmaskpb.FieldMask{Paths: paths},
+		ExpectedVersion: expectedVersion,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := laptopClient.UpdateLaptop(ctx, req)
+	if err != nil {
+		st, ok := status.FromError(err)
+		if ok && st.Code() == codes.Aborted {
+			log.Printf("laptop %s was modified by someone else", laptop.GetId())
+		} else {
+			log.Fatal("cannot update laptop: ", err)
+		}
+		return
+	}
+
+	log.Printf("updated laptop with id: %s, price: %.2f", res.GetLaptop().GetId(), res.GetLaptop().GetPriceUsd())
+}
+
+func deleteLaptop(laptopClient pb.LaptopServiceClient, laptopID string) {
+	req := &pb.DeleteLaptopRequest{Id: laptopID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := laptopClient.DeleteLaptop(ctx, req)
+	if err != nil {
+		log.Fatal("cannot delete laptop: ", err)
+	}
+
+	log.Printf("deleted laptop with id: %s", laptopID)
+}
+
+func restoreLaptop(laptopClient pb.LaptopServiceClient, laptopID string) {
+	req := &pb.RestoreLaptopRequest{Id: laptopID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := laptopClient.RestoreLaptop(ctx, req)
+	if err != nil {
+		log.Fatal("cannot restore laptop: ", err)
+	}
+
+	log.Printf("restored laptop with id: %s", res.GetLaptop().GetId())
+}
+
+func listDeletedLaptops(laptopClient pb.LaptopServiceClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := laptopClient.ListDeletedLaptops(ctx, &pb.ListDeletedLaptopsRequest{})
+	if err != nil {
+		log.Fatal("cannot list deleted laptops: ", err)
+	}
+
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return
+		}
+
+		if err != nil {
+			log.Fatal("cannot receive response: ", err)
+		}
+
+		laptop := res.GetLaptop()
+		log.Print("- deleted: ", laptop.GetId())
+		log.Print("  + deleted at: ", laptop.GetDeletedAt().AsTime())
+	}
+}
+
+func listLaptops(laptopClient pb.LaptopServiceClient, pageSize uint32) {
+	pageToken := ""
+
+	for page := 1; ; page++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		res, err := laptopClient.ListLaptops(ctx, &pb.ListLaptopsRequest{
+			PageSize:  pageSize,
+			PageToken: pageToken,
+		})
+		cancel()
+		if err != nil {
+			log.Fatal("cannot list laptops: ", err)
+		}
+
+		log.Printf("page %d:", page)
+		for _, laptop := range res.GetLaptops() {
+			log.Printf("- %s %s %s", laptop.GetId(), laptop.GetBrand(), laptop.GetName())
+		}
+
+		pageToken = res.GetNextPageToken()
+		if pageToken == "" {
+			return
+		}
+	}
+}
+
+// watchLaptops logs the laptop change events until the context is done
+func watchLaptops(ctx context.Context, laptopClient pb.LaptopServiceClient, filter *pb.Filter) {
+	stream, err := laptopClient.WatchLaptops(ctx, &pb.WatchRequest{Filter: filter})
+	if err != nil {
+		log.Fatal("cannot watch laptops: ", err)
+	}
+
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF || status.Code(err) == codes.Canceled || status.Code(err) == codes.DeadlineExceeded {
+			return
+		}
+
+		if err != nil {
+			log.Print("cannot receive event: ", err)
+			return
+		}
+
+		log.Printf("- %s: %s (version %d)", event.GetType(), event.GetLaptop().GetId(), event.GetLaptop().GetVersion())
+	}
+}
+
+func searchLaptop(laptopClient pb.LaptopServiceClient, req *pb.SearchLaptopRequest) {
+	log.Print("search filter: ", req.GetFilter())
+	if req.GetQuery() != "" {
+		log.Printf("search query: %q", req.GetQuery())
+	}
+	if req.GetFilterExpression() != "" {
+		log.Print("search filter expression: ", req.GetFilterExpression())
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	req := &pb.SearchLaptopRequest{Filter: filter}
 	stream, err := laptopClient.SearchLaptop(ctx, req)
 	if err != nil {
 		log.Fatal("cannot search laptop: ", err)
@@ -59,11 +248,45 @@ func searchLaptop(laptopClient pb.LaptopServiceClient, filter *pb.Filter) {
 			log.Fatal("cannot receive response: ", err)
 		}
 
+		if res.GetExplanation() != nil {
+			logExplanation(res.GetExplanation())
+			continue
+		}
+
 		laptop := res.GetLaptop()
 		log.Print("- found: ", laptop.GetId())
 		log.Print("  + brand: ", laptop.GetBrand())
 		log.Print("  + name: ", laptop.GetName())
 		log.Print("  + cpu cores: ", laptop.GetCpu().GetNumberOfCores())
+		log.Print("  + price: ", laptop.GetPriceUsd())
+	}
+}
+
+func logExplanation(explanation *pb.SearchExplanation) {
+	log.Printf("%d of %d laptops matched", explanation.GetMatchedCount(), explanation.GetScannedCount())
+	for _, rejection := range explanation.GetRejections() {
+		log.Printf("- %s rejected %d laptops", rejection.GetCriterion(), rejection.GetRejectedCount())
+	}
+	for _, nearMiss := range explanation.GetNearMisses() {
+		log.Printf("- near miss: %s only failed %s", nearMiss.GetLaptop().GetId(), nearMiss.GetFailedCriterion())
+	}
+}
+
+func searchFacets(laptopClient pb.LaptopServiceClient, filter *pb.Filter) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := laptopClient.SearchFacets(ctx, &pb.SearchFacetsRequest{Filter: filter})
+	if err != nil {
+		log.Fatal("cannot search facets: ", err)
+	}
+
+	log.Printf("%d laptops found", res.GetTotalCount())
+	for _, facet := range res.GetFacets() {
+		log.Print("- ", facet.GetName())
+		for _, bucket := range facet.GetBuckets() {
+			log.Printf("  + %s (%d)", bucket.GetValue(), bucket.GetCount())
+		}
 	}
 }
 
@@ -78,7 +301,20 @@ func testSearchLaptop(laptopClient pb.LaptopServiceClient) {
 		MinCupGhb:   2.5,
 		MinRam:      &pb.Memory{Value: 0, Unit: pb.Memory_GIGABYTE},
 	}
-	searchLaptop(laptopClient, filter)
+	searchLaptop(laptopClient, &pb.SearchLaptopRequest{Filter: filter})
+
+	// the three cheapest matching laptops
+	searchLaptop(laptopClient, &pb.SearchLaptopRequest{
+		Filter:     filter,
+		SortKey:    pb.SearchLaptopRequest_PRICE,
+		MaxResults: 3,
+	})
+
+	// the most relevant matches of a free text query
+	searchLaptop(laptopClient, &pb.SearchLaptopRequest{
+		Query:      "ryzen 7 rtx 2070",
+		MaxResults: 5,
+	})
 }
 
 func uploadImage(laptopClient pb.LaptopServiceClient, laptopID string, imagePath string) {
@@ -143,35 +379,328 @@ func uploadImage(laptopClient pb.LaptopServiceClient, laptopID string, imagePath
 	log.Printf("image uploaded with id: %s, size: %d", res.GetId(), res.GetSize())
 }
 
+func rateLaptop(laptopClient pb.LaptopServiceClient, laptopIDs []string, scores []float64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := laptopClient.RateLaptop(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot rate laptop: %v", err)
+	}
+
+	waitResponse := make(chan error)
+	// receive the responses in a separate go routine
+	go func() {
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				log.Print("no more responses")
+				waitResponse <- nil
+				return
+			}
+
+			if err != nil {
+				waitResponse <- fmt.Errorf("cannot receive stream response: %v", err)
+				return
+			}
+
+			log.Print("received response: ", res)
+		}
+	}()
+
+	for i, laptopID := range laptopIDs {
+		req := &pb.RateLaptopRequest{
+			LaptopId: laptopID,
+			Score:    scores[i],
+		}
+
+		err := stream.Send(req)
+		if err != nil {
+			return fmt.Errorf("cannot send stream request: %v - %v", err, stream.RecvMsg(nil))
+		}
+
+		log.Print("sent request: ", req)
+	}
+
+	err = stream.CloseSend()
+	if err != nil {
+		return fmt.Errorf("cannot close send: %v", err)
+	}
+
+	return <-waitResponse
+}
+
+func testRateLaptop(laptopClient pb.LaptopServiceClient) {
+	n := 3
+	laptopIDs := make([]string, n)
+	scores := make([]float64, n)
+
+	for i := 0; i < n; i++ {
+		laptop := sample.NewLaptop()
+		laptopIDs[i] = laptop.GetId()
+		scores[i] = sample.RandomLaptopScore()
+		createLaptop(laptopClient, laptop)
+	}
+
+	err := rateLaptop(laptopClient, laptopIDs, scores)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func testWatchLaptops(laptopClient pb.LaptopServiceClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		watchLaptops(ctx, laptopClient, nil)
+		close(done)
+	}()
+
+	// give the watcher time to subscribe before making changes
+	time.Sleep(500 * time.Millisecond)
+
+	laptop := sample.NewLaptop()
+	createLaptop(laptopClient, laptop)
+	laptop.PriceUsd = 1299
+	updateLaptop(laptopClient, laptop, 0, "price_usd")
+	deleteLaptop(laptopClient, laptop.GetId())
+
+	<-done
+}
+
 func testUploadImage(laptopClient pb.LaptopServiceClient) {
 	laptop := sample.NewLaptop()
 	createLaptop(laptopClient, laptop)
 	uploadImage(laptopClient, laptop.GetId(), "tmp/k-mean-algorithm.jpg")
 }
 
+func testGetLaptop(laptopClient pb.LaptopServiceClient) {
+	laptop := sample.NewLaptop()
+	createLaptop(laptopClient, laptop)
+	getLaptop(laptopClient, laptop.GetId())
+}
+
+func findSimilarLaptops(laptopClient pb.LaptopServiceClient, laptopID string, k uint32) {
+	req := &pb.FindSimilarLaptopsRequest{LaptopId: laptopID, K: k}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := laptopClient.FindSimilarLaptops(ctx, req)
+	if err != nil {
+		log.Fatal("cannot find similar laptops: ", err)
+	}
+
+	log.Printf("laptops similar to %s:", laptopID)
+	for _, similar := range res.GetSimilarLaptops() {
+		laptop := similar.GetLaptop()
+		log.Printf("- %s %s %s at distance %.3f", laptop.GetId(), laptop.GetBrand(), laptop.GetName(), similar.GetDistance())
+	}
+}
+
+func testFindSimilarLaptops(laptopClient pb.LaptopServiceClient) {
+	laptops := make([]*pb.Laptop, 10)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+		createLaptop(laptopClient, laptops[i])
+	}
+
+	findSimilarLaptops(laptopClient, laptops[0].GetId(), 5)
+}
+
+func testCreateLaptops(laptopClient pb.LaptopServiceClient) {
+	laptops := make([]*pb.Laptop, 5)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+	}
+
+	// the duplicate is rejected without aborting the rest of the batch
+	laptops = append(laptops, laptops[0])
+	createLaptops(laptopClient, laptops)
+}
+
+func testUpdateLaptop(laptopClient pb.LaptopServiceClient) {
+	laptop := sample.NewLaptop()
+	createLaptop(laptopClient, laptop)
+
+	laptop.PriceUsd = 999
+	updateLaptop(laptopClient, laptop, 1, "price_usd")
+
+	// the first update bumped the version, so this one is rejected
+	laptop.PriceUsd = 1099
+	updateLaptop(laptopClient, laptop, 1, "price_usd")
+}
+
+func testDeleteLaptop(laptopClient pb.LaptopServiceClient) {
+	laptop := sample.NewLaptop()
+	createLaptop(laptopClient, laptop)
+	uploadImage(laptopClient, laptop.GetId(), "tmp/k-mean-algorithm.jpg")
+	deleteLaptop(laptopClient, laptop.GetId())
+	getLaptop(laptopClient, laptop.GetId())
+	listDeletedLaptops(laptopClient)
+
+	restoreLaptop(laptopClient, laptop.GetId())
+	getLaptop(laptopClient, laptop.GetId())
+}
+
+// exportCatalog saves every laptop of the server and its image metadata to a binary file
+func exportCatalog(laptopClient pb.LaptopServiceClient, filename string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	stream, err := laptopClient.ExportCatalog(ctx, &pb.ExportCatalogRequest{})
+	if err != nil {
+		log.Fatal("cannot export catalog: ", err)
+	}
+
+	catalog := &pb.Catalog{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			log.Fatal("cannot receive catalog entry: ", err)
+		}
+
+		catalog.Entries = append(catalog.Entries, res.GetEntry())
+	}
+
+	err = serializer.WriteProtobufToBinaryFile(catalog, filename)
+	if err != nil {
+		log.Fatal("cannot write catalog: ", err)
+	}
+
+	log.Printf("exported %d laptops to %s", len(catalog.GetEntries()), filename)
+}
+
+// importCatalog sends the laptops of a file written by exportCatalog to the server
+func importCatalog(laptopClient pb.LaptopServiceClient, filename string, mode pb.ImportCatalogInfo_Mode) {
+	catalog := &pb.Catalog{}
+	err := serializer.ReadProtobufFromBinaryFile(filename, catalog)
+	if err != nil {
+		log.Fatal("cannot read catalog: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	stream, err := laptopClient.ImportCatalog(ctx)
+	if err != nil {
+		log.Fatal("cannot import catalog: ", err)
+	}
+
+	req := &pb.ImportCatalogRequest{
+		Data: &pb.ImportCatalogRequest_Info{
+			Info: &pb.ImportCatalogInfo{Mode: mode},
+		},
+	}
+
+	err = stream.Send(req)
+	if err != nil {
+		log.Fatal("cannot send import info to server: ", err, stream.RecvMsg(nil))
+	}
+
+	for _, entry := range catalog.GetEntries() {
+		req := &pb.ImportCatalogRequest{
+			Data: &pb.ImportCatalogRequest_Entry{Entry: entry},
+		}
+
+		err := stream.Send(req)
+		if err != nil {
+			log.Fatal("cannot send catalog entry to server: ", err, stream.RecvMsg(nil))
+		}
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		log.Fatal("cannot receive response: ", err)
+	}
+
+	log.Printf("imported %d laptops from %s, removed %d", res.GetImportedCount(), filename, res.GetRemovedCount())
+}
+
 func main() {
 	serverAddress := flag.String("address", "", "the server address")
+	filterExpression := flag.String("filter", "", "only search the laptops matching this expression, e.g. 'price_usd <= 2000 AND ram >= 16GB'")
+	exportFile := flag.String("export", "", "only export the catalog to this binary file")
+	importFile := flag.String("import", "", "only import the catalog from this binary file")
+	importMode := flag.String("import-mode", "merge", "how the catalog is imported: merge, or replace to remove the laptops missing from the catalog")
+	username := flag.String("username", "admin1", "the user to log in as, creating laptops and the catalog commands need an admin")
+	password := flag.String("password", "secret", "the password of the user")
 	flag.Parse()
 	log.Printf("dial server %s", *serverAddress)
 
-	conn, err := grpc.Dial(*serverAddress, grpc.WithInsecure())
+	authConn, err := grpc.Dial(*serverAddress, grpc.WithInsecure())
+	if err != nil {
+		log.Fatal("cannot connect to server: ", err)
+	}
+
+	accessToken, err := login(authConn, *username, *password)
+	authConn.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	interceptor := &authInterceptor{accessToken: accessToken}
+	conn, err := grpc.Dial(
+		*serverAddress,
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(interceptor.unary()),
+		grpc.WithStreamInterceptor(interceptor.stream()),
+	)
 	if err != nil {
 		log.Fatal("cannot connect to server: ", err)
 	}
 
 	laptopClient := pb.NewLaptopServiceClient(conn)
+
+	if *filterExpression != "" {
+		searchLaptop(laptopClient, &pb.SearchLaptopRequest{FilterExpression: *filterExpression})
+		return
+	}
+
+	if *exportFile != "" {
+		exportCatalog(laptopClient, *exportFile)
+		return
+	}
+
+	if *importFile != "" {
+		mode, ok := pb.ImportCatalogInfo_Mode_value[strings.ToUpper(*importMode)]
+		if !ok || mode == int32(pb.ImportCatalogInfo_UNKNOWN) {
+			log.Fatalf("invalid import mode %q, expected merge or replace", *importMode)
+		}
+
+		importCatalog(laptopClient, *importFile, pb.ImportCatalogInfo_Mode(mode))
+		return
+	}
+
 	//for i := 0; i < 10; i++ {
 	//	createLaptop(laptopClient, sample.NewLaptop())
 	//}
 
 	testUploadImage(laptopClient)
+	testGetLaptop(laptopClient)
+	testFindSimilarLaptops(laptopClient)
+	testCreateLaptops(laptopClient)
+	testUpdateLaptop(laptopClient)
+	testDeleteLaptop(laptopClient)
+	testRateLaptop(laptopClient)
+	testWatchLaptops(laptopClient)
+	listLaptops(laptopClient, 5)
 
 	filter := &pb.Filter{
 		MaxPriceUsd: 3000,
 		MinCpuCores: 4,
 		MinCupGhb:   2.5,
 		MinRam:      &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE},
+		Brands:      []string{"Apple", "Dell"},
+		MaxWeightKg: 2.5,
 	}
 
-	searchLaptop(laptopClient, filter)
+	searchLaptop(laptopClient, &pb.SearchLaptopRequest{Filter: filter, Explain: true})
+	searchFacets(laptopClient, filter)
 }
@@ -20,13 +20,27 @@ func unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServ
 const (
 	secretKey     = "secret"
 	tokenDuration = 15 * time.Minute
+	purgeInterval = time.Hour
 )
 
 func accessibleRoles() map[string][]string {
 	const laptopServicePath = "/LaptopService/"
 	return map[string][]string{
-		laptopServicePath + "CreateLaptop": {"admin"},
-		laptopServicePath + "UploadImage":  {"user"},
+		laptopServicePath + "CreateLaptop":       {"admin"},
+		laptopServicePath + "CreateLaptops":      {"admin"},
+		laptopServicePath + "UpdateLaptop":       {"admin"},
+		laptopServicePath + "DeleteLaptop":       {"admin"},
+		laptopServicePath + "RestoreLaptop":      {"admin"},
+		laptopServicePath + "ListDeletedLaptops": {"admin"},
+		laptopServicePath + "UploadImage":        {"admin", "user"},
+		laptopServicePath + "WatchLaptops":       {"admin"},
+		laptopServicePath + "RateLaptop":         {"admin", "user"},
+		laptopServicePath + "SaveSearch":         {"admin", "user"},
+		laptopServicePath + "ListSavedSearches":  {"admin", "user"},
+		laptopServicePath + "DeleteSavedSearch":  {"admin", "user"},
+		laptopServicePath + "StreamSearchAlerts": {"admin", "user"},
+		laptopServicePath + "ExportCatalog":      {"admin"},
+		laptopServicePath + "ImportCatalog":      {"admin"},
 	}
 }
 
@@ -47,27 +61,78 @@ func createUser(userStore service.UserStore, username, password, role string) er
 	return userStore.Save(user)
 }
 
+// purgeDeletedLaptops periodically removes the laptops that stayed in the trash longer than the retention period
+func purgeDeletedLaptops(laptopServer *service.LaptopServer, retention time.Duration) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, err := laptopServer.PurgeDeletedLaptops(retention)
+		if err != nil {
+			log.Print("cannot purge deleted laptops: ", err)
+		}
+	}
+}
+
+func newLaptopStore(kind string, shardCount int, folder string, snapshotInterval int) (service.LaptopStore, error) {
+	switch kind {
+	case "memory":
+		return service.NewInMemoryLaptopStore(), nil
+	case "sharded":
+		return service.NewShardedLaptopStore(shardCount), nil
+	case "file":
+		log.Printf("loading laptops from %s", folder)
+		return service.NewFileLaptopStore(folder, snapshotInterval)
+	default:
+		return nil, fmt.Errorf("unknown laptop store %q, expected memory, sharded or file", kind)
+	}
+}
+
 func main() {
 	port := flag.Int("port", 0, "server port")
+	trashRetention := flag.Duration("trash-retention", 30*24*time.Hour, "how long deleted laptops are kept before they are purged")
+	similarityWeights := flag.String("similarity-weights", "", "weights of the features compared by FindSimilarLaptops, e.g. price_usd=2,ram=0.5")
+	storeKind := flag.String("laptop-store", "memory", "where laptops are stored: memory, sharded to spread them over independently locked shards, or file to keep them across restarts")
+	shardCount := flag.Int("shards", service.DefaultShardCount, "number of shards when -laptop-store=sharded")
+	dataFolder := flag.String("data", "data", "folder of the laptop log and snapshots when -laptop-store=file")
+	snapshotInterval := flag.Int("snapshot-interval", service.DefaultSnapshotInterval, "number of logged changes after which the laptop log is compacted into a snapshot")
+	cacheSize := flag.Int("laptop-cache", 0, "number of laptops cached in front of the laptop store, 0 disables the cache")
 	flag.Parse()
 	log.Printf("start server on port %d", *port)
 
+	weights, err := service.ParseSimilarityWeights(*similarityWeights)
+	if err != nil {
+		log.Fatal("invalid similarity weights: ", err)
+	}
+
 	userStore := service.NewInMemoryUserStore()
-	err := seedUsers(userStore)
+	err = seedUsers(userStore)
 	if err != nil {
 		log.Fatal("cannot seed users")
 	}
 	jwtManager := service.NewJWTManager(secretKey, tokenDuration)
 	authServer := service.NewAuthServer(userStore, jwtManager)
 
-	laptopStore := service.NewInMemoryLaptopStore()
+	laptopStore, err := newLaptopStore(*storeKind, *shardCount, *dataFolder, *snapshotInterval)
+	if err != nil {
+		log.Fatal("cannot create laptop store: ", err)
+	}
+	if *cacheSize > 0 {
+		laptopStore = service.NewCachedLaptopStore(laptopStore, *cacheSize)
+	}
+
 	imageStore := service.NewDiskImageStore("img")
+	ratingStore := service.NewInMemoryRatingStore()
+	savedSearchStore := service.NewInMemorySavedSearchStore()
 
-	laptopServer := service.NewLaptopServer(laptopStore, imageStore)
+	laptopServer := service.NewLaptopServer(laptopStore, imageStore, ratingStore, savedSearchStore)
+	laptopServer.SimilarityWeights = weights
+	go purgeDeletedLaptops(laptopServer, *trashRetention)
 
 	interceptor := service.NewAuthInterceptor(jwtManager, accessibleRoles())
 	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(interceptor.Unary()))
+		grpc.UnaryInterceptor(interceptor.Unary()),
+		grpc.StreamInterceptor(interceptor.Stream()))
 
 	pb.RegisterAuthServiceServer(grpcServer, authServer)
 	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/sample"
+	"github.com/Adetunjii/go-grpc/service"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"net"
+	"testing"
+	"time"
+)
+
+// startTestServer serves the laptop and auth services behind the roles of the server, with the seeded users
+func startTestServer(t *testing.T) *grpc.ClientConn {
+	userStore := service.NewInMemoryUserStore()
+	require.NoError(t, seedUsers(userStore))
+
+	jwtManager := service.NewJWTManager(secretKey, tokenDuration)
+	laptopServer := service.NewLaptopServer(service.NewInMemoryLaptopStore(), service.NewDiskImageStore(t.TempDir()), nil, nil)
+
+	interceptor := service.NewAuthInterceptor(jwtManager, accessibleRoles())
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(interceptor.Unary()),
+		grpc.StreamInterceptor(interceptor.Stream()))
+
+	pb.RegisterAuthServiceServer(grpcServer, service.NewAuthServer(userStore, jwtManager))
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func loggedIn(t *testing.T, conn *grpc.ClientConn, username string) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	res, err := pb.NewAuthServiceClient(conn).Login(ctx, &pb.LoginRequest{Username: username, Password: "secret"})
+	require.NoError(t, err)
+
+	return metadata.AppendToOutgoingContext(ctx, "authorization", res.GetAccessToken())
+}
+
+func TestServerUploadImageRoles(t *testing.T) {
+	t.Parallel()
+
+	conn := startTestServer(t)
+	laptopClient := pb.NewLaptopServiceClient(conn)
+
+	laptop := sample.NewLaptop()
+	_, err := laptopClient.CreateLaptop(loggedIn(t, conn, "admin1"), &pb.CreatelaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	// the admin who created the laptop can attach an image to it, like any user
+	for _, username := range []string{"admin1", "user1"} {
+		stream, err := laptopClient.UploadImage(loggedIn(t, conn, username))
+		require.NoError(t, err)
+
+		err = stream.Send(&pb.UploadImageRequest{
+			Data: &pb.UploadImageRequest_Info{Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: ".jpg"}},
+		})
+		require.NoError(t, err)
+
+		err = stream.Send(&pb.UploadImageRequest{
+			Data: &pb.UploadImageRequest_ChunkData{ChunkData: []byte("image")},
+		})
+		require.NoError(t, err)
+
+		res, err := stream.CloseAndRecv()
+		require.NoError(t, err, username)
+		require.NotEmpty(t, res.GetId())
+	}
+}
@@ -0,0 +1,127 @@
+package expression
+
+import (
+	"fmt"
+	"github.com/Adetunjii/go-grpc/pb"
+	"strconv"
+	"strings"
+)
+
+// Expression is a parsed filter expression
+type Expression interface {
+	// Evaluate reports whether the laptop satisfies the expression
+	Evaluate(laptop *pb.Laptop) bool
+	String() string
+}
+
+type andNode struct {
+	left  Expression
+	right Expression
+}
+
+func (node *andNode) Evaluate(laptop *pb.Laptop) bool {
+	return node.left.Evaluate(laptop) && node.right.Evaluate(laptop)
+}
+
+func (node *andNode) String() string {
+	return fmt.Sprintf("(%s AND %s)", node.left, node.right)
+}
+
+type orNode struct {
+	left  Expression
+	right Expression
+}
+
+func (node *orNode) Evaluate(laptop *pb.Laptop) bool {
+	return node.left.Evaluate(laptop) || node.right.Evaluate(laptop)
+}
+
+func (node *orNode) String() string {
+	return fmt.Sprintf("(%s OR %s)", node.left, node.right)
+}
+
+type notNode struct {
+	operand Expression
+}
+
+func (node *notNode) Evaluate(laptop *pb.Laptop) bool {
+	return !node.operand.Evaluate(laptop)
+}
+
+func (node *notNode) String() string {
+	return fmt.Sprintf("NOT %s", node.operand)
+}
+
+// comparisonNode compares a laptop field with literals.
+// A repeated field matches if any of its values matches, != and NOT negate that.
+type comparisonNode struct {
+	field    *field
+	operator string // one of = != < <= > >= IN
+	literals []value
+}
+
+func (node *comparisonNode) Evaluate(laptop *pb.Laptop) bool {
+	if node.operator == "!=" {
+		return !node.any(laptop, "=")
+	}
+
+	return node.any(laptop, node.operator)
+}
+
+func (node *comparisonNode) any(laptop *pb.Laptop, operator string) bool {
+	for _, actual := range node.field.values(laptop) {
+		for _, literal := range node.literals {
+			if node.compare(actual, operator, literal) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (node *comparisonNode) compare(actual value, operator string, literal value) bool {
+	switch node.field.kind {
+	case numberKind, memoryKind:
+		switch operator {
+		case "<":
+			return actual.number < literal.number
+		case "<=":
+			return actual.number <= literal.number
+		case ">":
+			return actual.number > literal.number
+		case ">=":
+			return actual.number >= literal.number
+		default:
+			return actual.number == literal.number
+		}
+	case boolKind:
+		return actual.boolean == literal.boolean
+	default:
+		return strings.EqualFold(strings.TrimSpace(actual.text), strings.TrimSpace(literal.text))
+	}
+}
+
+func (node *comparisonNode) String() string {
+	literals := make([]string, len(node.literals))
+	for i, literal := range node.literals {
+		switch node.field.kind {
+		case numberKind:
+			literals[i] = strconv.FormatFloat(literal.number, 'g', -1, 64)
+		case memoryKind:
+			literals[i] = strconv.FormatFloat(literal.number/8, 'f', -1, 64) + "B"
+		case boolKind:
+			literals[i] = strconv.FormatBool(literal.boolean)
+		case enumKind:
+			literals[i] = literal.text
+		default:
+			literals[i] = strconv.Quote(literal.text)
+		}
+	}
+
+	if node.operator == "IN" {
+		return fmt.Sprintf("%s IN (%s)", node.field.name, strings.Join(literals, ", "))
+	}
+
+	return fmt.Sprintf("%s %s %s", node.field.name, node.operator, literals[0])
+}
@@ -0,0 +1,185 @@
+package expression
+
+import (
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/units"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"sort"
+	"strings"
+)
+
+type valueKind int
+
+const (
+	numberKind valueKind = iota
+	memoryKind           // a size in bits, written with a unit such as 16GB
+	stringKind
+	enumKind
+	boolKind
+)
+
+func (kind valueKind) String() string {
+	switch kind {
+	case numberKind:
+		return "a number"
+	case memoryKind:
+		return "a memory size such as 16GB"
+	case stringKind:
+		return "a string"
+	case enumKind:
+		return "an enum value"
+	default:
+		return "true or false"
+	}
+}
+
+// value is either a field of a laptop or a literal of the expression
+type value struct {
+	number  float64 // numbers and memory sizes
+	text    string  // strings and enum names
+	boolean bool
+}
+
+// field is a laptop field that can be used in an expression.
+// A field of a repeated message, such as gpus.name, has one value per element.
+type field struct {
+	name   string
+	kind   valueKind
+	enum   protoreflect.EnumDescriptor
+	values func(laptop *pb.Laptop) []value
+}
+
+func number(x float64) []value {
+	return []value{{number: x}}
+}
+
+func text(s string) []value {
+	return []value{{text: s}}
+}
+
+func boolean(b bool) []value {
+	return []value{{boolean: b}}
+}
+
+func memory(m *pb.Memory) value {
+	return value{number: float64(units.MemoryBits(m))}
+}
+
+var fields = map[string]*field{}
+
+func register(name string, kind valueKind, values func(laptop *pb.Laptop) []value) {
+	fields[name] = &field{name: name, kind: kind, values: values}
+}
+
+func registerEnum(name string, enum protoreflect.EnumDescriptor, values func(laptop *pb.Laptop) []value) {
+	fields[name] = &field{name: name, kind: enumKind, enum: enum, values: values}
+}
+
+func init() {
+	register("id", stringKind, func(laptop *pb.Laptop) []value { return text(laptop.GetId()) })
+	register("brand", stringKind, func(laptop *pb.Laptop) []value { return text(laptop.GetBrand()) })
+	register("name", stringKind, func(laptop *pb.Laptop) []value { return text(laptop.GetName()) })
+	register("price_usd", numberKind, func(laptop *pb.Laptop) []value { return number(laptop.GetPriceUsd()) })
+	register("release_year", numberKind, func(laptop *pb.Laptop) []value { return number(float64(laptop.GetReleaseYear())) })
+
+	register("cpu.brand", stringKind, func(laptop *pb.Laptop) []value { return text(laptop.GetCpu().GetBrand()) })
+	register("cpu.name", stringKind, func(laptop *pb.Laptop) []value { return text(laptop.GetCpu().GetName()) })
+	register("cpu.number_of_cores", numberKind, func(laptop *pb.Laptop) []value {
+		return number(float64(laptop.GetCpu().GetNumberOfCores()))
+	})
+	register("cpu.number_of_threads", numberKind, func(laptop *pb.Laptop) []value {
+		return number(float64(laptop.GetCpu().GetNumberOfThreads()))
+	})
+	register("cpu.min_ghz", numberKind, func(laptop *pb.Laptop) []value { return number(laptop.GetCpu().GetMinGhz()) })
+	register("cpu.max_ghz", numberKind, func(laptop *pb.Laptop) []value { return number(laptop.GetCpu().GetMaxGhz()) })
+
+	register("ram", memoryKind, func(laptop *pb.Laptop) []value { return []value{memory(laptop.GetRam())} })
+
+	gpus := func(get func(gpu *pb.GPU) value) func(laptop *pb.Laptop) []value {
+		return func(laptop *pb.Laptop) []value {
+			values := make([]value, len(laptop.GetGpus()))
+			for i, gpu := range laptop.GetGpus() {
+				values[i] = get(gpu)
+			}
+			return values
+		}
+	}
+	register("gpus.brand", stringKind, gpus(func(gpu *pb.GPU) value { return value{text: gpu.GetBrand()} }))
+	register("gpus.name", stringKind, gpus(func(gpu *pb.GPU) value { return value{text: gpu.GetName()} }))
+	register("gpus.min_ghz", numberKind, gpus(func(gpu *pb.GPU) value { return value{number: gpu.GetMinGhz()} }))
+	register("gpus.max_ghz", numberKind, gpus(func(gpu *pb.GPU) value { return value{number: gpu.GetMaxGhz()} }))
+	register("gpus.memory", memoryKind, gpus(func(gpu *pb.GPU) value { return memory(gpu.GetMemory()) }))
+
+	storages := func(get func(storage *pb.Storage) value) func(laptop *pb.Laptop) []value {
+		return func(laptop *pb.Laptop) []value {
+			values := make([]value, len(laptop.GetStorages()))
+			for i, storage := range laptop.GetStorages() {
+				values[i] = get(storage)
+			}
+			return values
+		}
+	}
+	registerEnum("storages.driver", pb.Storage_UNKNOWN.Descriptor(), storages(func(storage *pb.Storage) value {
+		return value{text: storage.GetDriver().String()}
+	}))
+	register("storages.memory", memoryKind, storages(func(storage *pb.Storage) value { return memory(storage.GetMemory()) }))
+
+	register("screen.size_inch", numberKind, func(laptop *pb.Laptop) []value {
+		return number(float64(laptop.GetScreen().GetSizeInch()))
+	})
+	register("screen.resolution.width", numberKind, func(laptop *pb.Laptop) []value {
+		return number(float64(laptop.GetScreen().GetResolution().GetWidth()))
+	})
+	register("screen.resolution.height", numberKind, func(laptop *pb.Laptop) []value {
+		return number(float64(laptop.GetScreen().GetResolution().GetHeight()))
+	})
+	registerEnum("screen.panel", pb.Screen_UNKNOWN.Descriptor(), func(laptop *pb.Laptop) []value {
+		return text(laptop.GetScreen().GetPanel().String())
+	})
+	register("screen.multitouch", boolKind, func(laptop *pb.Laptop) []value { return boolean(laptop.GetScreen().GetMultitouch()) })
+
+	registerEnum("keyboard.layout", pb.Keyboard_UNKNOWN.Descriptor(), func(laptop *pb.Laptop) []value {
+		return text(laptop.GetKeyboard().GetLayout().String())
+	})
+	register("keyboard.backlit", boolKind, func(laptop *pb.Laptop) []value { return boolean(laptop.GetKeyboard().GetBacklit()) })
+
+	register("weight_kg", numberKind, func(laptop *pb.Laptop) []value {
+		weight, ok := units.WeightKg(laptop)
+		if !ok {
+			return nil
+		}
+		return number(weight)
+	})
+}
+
+// fieldNames lists every field usable in an expression, for error messages
+func fieldNames() string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// enumNames lists the values of the enum, UNKNOWN excepted
+func enumNames(enum protoreflect.EnumDescriptor) []string {
+	names := []string{}
+	for i := 0; i < enum.Values().Len(); i++ {
+		name := string(enum.Values().Get(i).Name())
+		if name != "UNKNOWN" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+var memoryUnits = map[string]pb.Memory_Unit{
+	"B":  pb.Memory_BYTE,
+	"KB": pb.Memory_KILOBYTE,
+	"MB": pb.Memory_MEGABYTE,
+	"GB": pb.Memory_GIGABYTE,
+	"TB": pb.Memory_TERABYTE,
+}
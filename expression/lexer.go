@@ -0,0 +1,148 @@
+package expression
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError reports an invalid expression along with the column where the problem starts
+type SyntaxError struct {
+	Column  int // 1-based, counted in characters
+	Message string
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", err.Column, err.Message)
+}
+
+func errorAt(column int, format string, args ...interface{}) *SyntaxError {
+	return &SyntaxError{Column: column, Message: fmt.Sprintf(format, args...)}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+)
+
+type token struct {
+	kind   tokenKind
+	text   string // the identifier, operator, number digits or unquoted string
+	unit   string // letters directly following a number, such as GB in 16GB
+	column int
+}
+
+func (tok token) describe() string {
+	switch tok.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return fmt.Sprintf("string %q", tok.text)
+	case tokenNumber:
+		return fmt.Sprintf("number %s%s", tok.text, tok.unit)
+	default:
+		return fmt.Sprintf("%q", tok.text)
+	}
+}
+
+// lex splits the source into tokens, the last one is always tokenEOF
+func lex(source string) ([]token, error) {
+	runes := []rune(source)
+	tokens := []token{}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		column := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, text: "(", column: column})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, text: ")", column: column})
+			i++
+
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", column: column})
+			i++
+
+		case strings.ContainsRune("=!<>", r):
+			operator := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				operator += "="
+			}
+			if operator == "!" {
+				return nil, errorAt(column, "unexpected character '!', did you mean '!='")
+			}
+			if operator == "==" {
+				operator = "="
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenOperator, text: operator, column: column})
+			i += len([]rune(operator))
+
+		case r == '"':
+			text := strings.Builder{}
+			closed := false
+
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					text.WriteRune(runes[i])
+					continue
+				}
+				if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				text.WriteRune(runes[i])
+			}
+
+			if !closed {
+				return nil, errorAt(column, "unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text.String(), column: column})
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			digits := string(runes[start:i])
+
+			start = i
+			for i < len(runes) && unicode.IsLetter(runes[i]) {
+				i++
+			}
+			unit := string(runes[start:i])
+
+			tokens = append(tokens, token{kind: tokenNumber, text: digits, unit: unit, column: column})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), column: column})
+
+		default:
+			return nil, errorAt(column, "unexpected character %q", r)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, column: len(runes) + 1}), nil
+}
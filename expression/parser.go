@@ -0,0 +1,248 @@
+// Package expression parses textual laptop filters such as
+//
+//	price_usd <= 2000 AND cpu.number_of_cores >= 4 AND ram >= 16GB AND brand IN ("Dell", "Apple")
+//
+// Comparisons use = != < <= > >= or IN, and can be combined with AND, OR, NOT and parentheses.
+// Keywords are case-insensitive, and so are string and enum comparisons.
+package expression
+
+import (
+	"github.com/Adetunjii/go-grpc/units"
+	"strconv"
+	"strings"
+)
+
+// Parse parses the source into an expression, any error is a *SyntaxError
+func Parse(source string) (Expression, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, errorAt(p.peek().column, "expression is empty")
+	}
+
+	expression, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, errorAt(tok.column, "unexpected %s, expected AND, OR or end of expression", tok.describe())
+	}
+
+	return expression, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+// keyword reports whether the next token is the given keyword, and consumes it if so
+func (p *parser) keyword(keyword string) bool {
+	tok := p.peek()
+	if tok.kind == tokenIdent && strings.EqualFold(tok.text, keyword) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) parseOr() (Expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expression, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expression, error) {
+	if p.keyword("NOT") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expression, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenLeftParen:
+		expression, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.kind != tokenRightParen {
+			return nil, errorAt(closing.column, "unexpected %s, expected ')' to close the '(' at column %d", closing.describe(), tok.column)
+		}
+		return expression, nil
+
+	case tokenIdent:
+		return p.parseComparison(tok)
+
+	default:
+		return nil, errorAt(tok.column, "unexpected %s, expected a field name or '('", tok.describe())
+	}
+}
+
+func (p *parser) parseComparison(name token) (Expression, error) {
+	f := fields[strings.ToLower(name.text)]
+	if f == nil {
+		return nil, errorAt(name.column, "unknown field %q, expected one of %s", name.text, fieldNames())
+	}
+
+	if p.keyword("IN") {
+		return p.parseIn(f)
+	}
+
+	operator := p.next()
+	if operator.kind != tokenOperator {
+		return nil, errorAt(operator.column, "unexpected %s, expected a comparison operator after %s", operator.describe(), f.name)
+	}
+
+	if operator.text != "=" && operator.text != "!=" && f.kind != numberKind && f.kind != memoryKind {
+		return nil, errorAt(operator.column, "operator %s cannot be used with %s, only = != and IN can", operator.text, f.name)
+	}
+
+	literal, err := p.parseLiteral(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &comparisonNode{field: f, operator: operator.text, literals: []value{literal}}, nil
+}
+
+func (p *parser) parseIn(f *field) (Expression, error) {
+	open := p.next()
+	if open.kind != tokenLeftParen {
+		return nil, errorAt(open.column, "unexpected %s, expected '(' after IN", open.describe())
+	}
+
+	literals := []value{}
+	for {
+		literal, err := p.parseLiteral(f)
+		if err != nil {
+			return nil, err
+		}
+		literals = append(literals, literal)
+
+		tok := p.next()
+		if tok.kind == tokenRightParen {
+			break
+		}
+		if tok.kind != tokenComma {
+			return nil, errorAt(tok.column, "unexpected %s, expected ',' or ')' in the IN list", tok.describe())
+		}
+	}
+
+	return &comparisonNode{field: f, operator: "IN", literals: literals}, nil
+}
+
+// parseLiteral parses a value of the kind of the field
+func (p *parser) parseLiteral(f *field) (value, error) {
+	tok := p.next()
+	mismatch := func() (value, error) {
+		if f.kind == enumKind {
+			return value{}, errorAt(tok.column, "unexpected %s, %s expects one of %s", tok.describe(), f.name, strings.Join(enumNames(f.enum), ", "))
+		}
+		return value{}, errorAt(tok.column, "unexpected %s, %s expects %s", tok.describe(), f.name, f.kind)
+	}
+
+	switch f.kind {
+	case numberKind, memoryKind:
+		if tok.kind != tokenNumber {
+			return mismatch()
+		}
+
+		number, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return value{}, errorAt(tok.column, "invalid number %q", tok.text)
+		}
+
+		if f.kind == numberKind {
+			if tok.unit != "" {
+				return value{}, errorAt(tok.column, "unexpected unit %q, %s expects a plain number", tok.unit, f.name)
+			}
+			return value{number: number}, nil
+		}
+
+		unit, ok := memoryUnits[strings.ToUpper(tok.unit)]
+		if !ok {
+			return value{}, errorAt(tok.column, "invalid memory size %s%s, expected a unit among B, KB, MB, GB and TB", tok.text, tok.unit)
+		}
+		return value{number: number * float64(units.ToBit(1, unit))}, nil
+
+	case boolKind:
+		if tok.kind == tokenIdent && (strings.EqualFold(tok.text, "true") || strings.EqualFold(tok.text, "false")) {
+			return value{boolean: strings.EqualFold(tok.text, "true")}, nil
+		}
+		return mismatch()
+
+	case enumKind:
+		if tok.kind != tokenIdent && tok.kind != tokenString {
+			return mismatch()
+		}
+
+		for _, name := range enumNames(f.enum) {
+			if strings.EqualFold(name, tok.text) {
+				return value{text: name}, nil
+			}
+		}
+		return mismatch()
+
+	default:
+		if tok.kind != tokenString {
+			return value{}, errorAt(tok.column, "unexpected %s, %s expects a double quoted string", tok.describe(), f.name)
+		}
+		return value{text: tok.text}, nil
+	}
+}
@@ -0,0 +1,129 @@
+package expression
+
+import (
+	"errors"
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newLaptop() *pb.Laptop {
+	return &pb.Laptop{
+		Brand:    "Dell",
+		Name:     "XPS 15",
+		PriceUsd: 1800,
+		Cpu: &pb.CPU{
+			Brand:         "Intel",
+			Name:          "Core i7",
+			NumberOfCores: 6,
+			MinGhz:        2.6,
+			MaxGhz:        4.5,
+		},
+		Ram: &pb.Memory{Value: 16384, Unit: pb.Memory_MEGABYTE},
+		Gpus: []*pb.GPU{
+			{Brand: "Intel", Name: "UHD 630"},
+			{Brand: "NVIDIA", Name: "GTX 1650", Memory: &pb.Memory{Value: 4, Unit: pb.Memory_GIGABYTE}},
+		},
+		Storages: []*pb.Storage{
+			{Driver: pb.Storage_SSD, Memory: &pb.Memory{Value: 1, Unit: pb.Memory_TERABYTE}},
+		},
+		Screen:      &pb.Screen{SizeInch: 15.6, Panel: pb.Screen_OLED, Multitouch: true},
+		Keyboard:    &pb.Keyboard{Layout: pb.Keyboard_QWERTY, Backlit: true},
+		Weight:      &pb.Laptop_WeightLb{WeightLb: 4},
+		ReleaseYear: 2019,
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		source   string
+		expected bool
+	}{
+		{`price_usd <= 2000 AND cpu.number_of_cores >= 4 AND ram >= 16GB AND brand IN ("Dell","Apple")`, true},
+		{`price_usd < 1800`, false},
+		{`ram = 16384MB AND ram > 15.5gb`, true},
+		{`brand = "dell"`, true},
+		{`brand != "Dell" OR release_year = 2019`, true},
+		{`NOT (brand = "Dell" OR brand = "HP")`, false},
+		{`not brand = "HP" and cpu.min_ghz >= 2.5`, true},
+		{`brand = "HP" OR brand = "Dell" AND price_usd > 5000`, false},
+		{`(brand = "HP" OR brand = "Dell") AND price_usd < 5000`, true},
+		{`gpus.brand = "nvidia" AND gpus.memory >= 4GB`, true},
+		{`gpus.name = "RTX 2070"`, false},
+		{`gpus.name != "RTX 2070"`, true},
+		{`gpus.brand != "Intel"`, false},
+		{`storages.driver = SSD AND storages.memory >= 512GB`, true},
+		{`screen.panel IN (OLED, "ops") AND screen.multitouch = true`, true},
+		{`keyboard.layout = AZERTY`, false},
+		{`keyboard.backlit = false`, false},
+		{`weight_kg < 2`, true},
+		{`screen.size_inch >= 15`, true},
+	}
+
+	laptop := newLaptop()
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.source, func(t *testing.T) {
+			t.Parallel()
+
+			expr, err := Parse(tc.source)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, expr.Evaluate(laptop))
+
+			// the printed form parses back to the same expression
+			reparsed, err := Parse(expr.String())
+			require.NoError(t, err)
+			require.Equal(t, expr.String(), reparsed.String())
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		source  string
+		column  int
+		message string
+	}{
+		{``, 1, "expression is empty"},
+		{`price_usd <= `, 14, "end of expression"},
+		{`pric <= 10`, 1, `unknown field "pric"`},
+		{`price_usd <= 10 AND`, 20, "expected a field name"},
+		{`price_usd 10`, 11, "expected a comparison operator"},
+		{`price_usd ! 10`, 11, "did you mean '!='"},
+		{`brand = Dell`, 9, "expects a double quoted string"},
+		{`brand < "Dell"`, 7, "operator < cannot be used with brand"},
+		{`brand = "Dell`, 9, "unterminated string"},
+		{`ram >= 16`, 8, "expected a unit"},
+		{`ram >= 16 GB`, 8, "expected a unit"},
+		{`price_usd <= 2000USD`, 14, `unexpected unit "USD"`},
+		{`price_usd <= "2000"`, 14, "price_usd expects a number"},
+		{`screen.panel = LCD`, 16, "expects one of OPS, OLED"},
+		{`keyboard.backlit = yes`, 20, "expects true or false"},
+		{`brand IN "Dell"`, 10, "expected '(' after IN"},
+		{`brand IN ("Dell" "HP")`, 18, "expected ',' or ')'"},
+		{`(brand = "Dell"`, 16, "expected ')' to close the '(' at column 1"},
+		{`brand = "Dell")`, 15, "expected AND, OR or end of expression"},
+		{`price_usd <= 1.2.3`, 14, "invalid number"},
+		{`price_usd <= 10 # comment`, 17, "unexpected character '#'"},
+		{`brand = "é" AND x = 1`, 17, `unknown field "x"`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.source, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Parse(tc.source)
+			require.Error(t, err)
+
+			var syntaxErr *SyntaxError
+			require.True(t, errors.As(err, &syntaxErr))
+			require.Equal(t, tc.column, syntaxErr.Column, syntaxErr.Error())
+			require.Contains(t, syntaxErr.Message, tc.message)
+		})
+	}
+}
@@ -21,6 +21,24 @@ type LaptopServiceClient interface {
 	CreateLaptop(ctx context.Context, in *CreatelaptopRequest, opts ...grpc.CallOption) (*CreateLaptopResponse, error)
 	SearchLaptop(ctx context.Context, in *SearchLaptopRequest, opts ...grpc.CallOption) (LaptopService_SearchLaptopClient, error)
 	UploadImage(ctx context.Context, opts ...grpc.CallOption) (LaptopService_UploadImageClient, error)
+	GetLaptop(ctx context.Context, in *GetLaptopRequest, opts ...grpc.CallOption) (*GetLaptopResponse, error)
+	UpdateLaptop(ctx context.Context, in *UpdateLaptopRequest, opts ...grpc.CallOption) (*UpdateLaptopResponse, error)
+	DeleteLaptop(ctx context.Context, in *DeleteLaptopRequest, opts ...grpc.CallOption) (*DeleteLaptopResponse, error)
+	ListDeletedLaptops(ctx context.Context, in *ListDeletedLaptopsRequest, opts ...grpc.CallOption) (LaptopService_ListDeletedLaptopsClient, error)
+	RestoreLaptop(ctx context.Context, in *RestoreLaptopRequest, opts ...grpc.CallOption) (*RestoreLaptopResponse, error)
+	WatchLaptops(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (LaptopService_WatchLaptopsClient, error)
+	ListLaptops(ctx context.Context, in *ListLaptopsRequest, opts ...grpc.CallOption) (*ListLaptopsResponse, error)
+	RateLaptop(ctx context.Context, opts ...grpc.CallOption) (LaptopService_RateLaptopClient, error)
+	CreateLaptops(ctx context.Context, opts ...grpc.CallOption) (LaptopService_CreateLaptopsClient, error)
+	SearchFacets(ctx context.Context, in *SearchFacetsRequest, opts ...grpc.CallOption) (*SearchFacetsResponse, error)
+	SaveSearch(ctx context.Context, in *SaveSearchRequest, opts ...grpc.CallOption) (*SaveSearchResponse, error)
+	ListSavedSearches(ctx context.Context, in *ListSavedSearchesRequest, opts ...grpc.CallOption) (*ListSavedSearchesResponse, error)
+	DeleteSavedSearch(ctx context.Context, in *DeleteSavedSearchRequest, opts ...grpc.CallOption) (*DeleteSavedSearchResponse, error)
+	StreamSearchAlerts(ctx context.Context, in *StreamSearchAlertsRequest, opts ...grpc.CallOption) (LaptopService_StreamSearchAlertsClient, error)
+	FindSimilarLaptops(ctx context.Context, in *FindSimilarLaptopsRequest, opts ...grpc.CallOption) (*FindSimilarLaptopsResponse, error)
+	ExplainSearch(ctx context.Context, in *ExplainSearchRequest, opts ...grpc.CallOption) (*ExplainSearchResponse, error)
+	ExportCatalog(ctx context.Context, in *ExportCatalogRequest, opts ...grpc.CallOption) (LaptopService_ExportCatalogClient, error)
+	ImportCatalog(ctx context.Context, opts ...grpc.CallOption) (LaptopService_ImportCatalogClient, error)
 }
 
 type laptopServiceClient struct {
@@ -106,6 +124,332 @@ func (x *laptopServiceUploadImageClient) CloseAndRecv() (*UploadImageResponse, e
 	return m, nil
 }
 
+func (c *laptopServiceClient) GetLaptop(ctx context.Context, in *GetLaptopRequest, opts ...grpc.CallOption) (*GetLaptopResponse, error) {
+	out := new(GetLaptopResponse)
+	err := c.cc.Invoke(ctx, "/LaptopService/GetLaptop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) UpdateLaptop(ctx context.Context, in *UpdateLaptopRequest, opts ...grpc.CallOption) (*UpdateLaptopResponse, error) {
+	out := new(UpdateLaptopResponse)
+	err := c.cc.Invoke(ctx, "/LaptopService/UpdateLaptop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) DeleteLaptop(ctx context.Context, in *DeleteLaptopRequest, opts ...grpc.CallOption) (*DeleteLaptopResponse, error) {
+	out := new(DeleteLaptopResponse)
+	err := c.cc.Invoke(ctx, "/LaptopService/DeleteLaptop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) ListDeletedLaptops(ctx context.Context, in *ListDeletedLaptopsRequest, opts ...grpc.CallOption) (LaptopService_ListDeletedLaptopsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[2], "/LaptopService/ListDeletedLaptops", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceListDeletedLaptopsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LaptopService_ListDeletedLaptopsClient interface {
+	Recv() (*ListDeletedLaptopsResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceListDeletedLaptopsClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceListDeletedLaptopsClient) Recv() (*ListDeletedLaptopsResponse, error) {
+	m := new(ListDeletedLaptopsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) RestoreLaptop(ctx context.Context, in *RestoreLaptopRequest, opts ...grpc.CallOption) (*RestoreLaptopResponse, error) {
+	out := new(RestoreLaptopResponse)
+	err := c.cc.Invoke(ctx, "/LaptopService/RestoreLaptop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) WatchLaptops(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (LaptopService_WatchLaptopsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[3], "/LaptopService/WatchLaptops", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceWatchLaptopsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LaptopService_WatchLaptopsClient interface {
+	Recv() (*LaptopEvent, error)
+	grpc.ClientStream
+}
+
+type laptopServiceWatchLaptopsClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceWatchLaptopsClient) Recv() (*LaptopEvent, error) {
+	m := new(LaptopEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) ListLaptops(ctx context.Context, in *ListLaptopsRequest, opts ...grpc.CallOption) (*ListLaptopsResponse, error) {
+	out := new(ListLaptopsResponse)
+	err := c.cc.Invoke(ctx, "/LaptopService/ListLaptops", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) RateLaptop(ctx context.Context, opts ...grpc.CallOption) (LaptopService_RateLaptopClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[4], "/LaptopService/RateLaptop", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceRateLaptopClient{stream}
+	return x, nil
+}
+
+type LaptopService_RateLaptopClient interface {
+	Send(*RateLaptopRequest) error
+	Recv() (*RateLaptopResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceRateLaptopClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceRateLaptopClient) Send(m *RateLaptopRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *laptopServiceRateLaptopClient) Recv() (*RateLaptopResponse, error) {
+	m := new(RateLaptopResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) CreateLaptops(ctx context.Context, opts ...grpc.CallOption) (LaptopService_CreateLaptopsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[5], "/LaptopService/CreateLaptops", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceCreateLaptopsClient{stream}
+	return x, nil
+}
+
+type LaptopService_CreateLaptopsClient interface {
+	Send(*CreateLaptopsRequest) error
+	CloseAndRecv() (*CreateLaptopsResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceCreateLaptopsClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceCreateLaptopsClient) Send(m *CreateLaptopsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *laptopServiceCreateLaptopsClient) CloseAndRecv() (*CreateLaptopsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(CreateLaptopsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) SearchFacets(ctx context.Context, in *SearchFacetsRequest, opts ...grpc.CallOption) (*SearchFacetsResponse, error) {
+	out := new(SearchFacetsResponse)
+	err := c.cc.Invoke(ctx, "/LaptopService/SearchFacets", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) SaveSearch(ctx context.Context, in *SaveSearchRequest, opts ...grpc.CallOption) (*SaveSearchResponse, error) {
+	out := new(SaveSearchResponse)
+	err := c.cc.Invoke(ctx, "/LaptopService/SaveSearch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) ListSavedSearches(ctx context.Context, in *ListSavedSearchesRequest, opts ...grpc.CallOption) (*ListSavedSearchesResponse, error) {
+	out := new(ListSavedSearchesResponse)
+	err := c.cc.Invoke(ctx, "/LaptopService/ListSavedSearches", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) DeleteSavedSearch(ctx context.Context, in *DeleteSavedSearchRequest, opts ...grpc.CallOption) (*DeleteSavedSearchResponse, error) {
+	out := new(DeleteSavedSearchResponse)
+	err := c.cc.Invoke(ctx, "/LaptopService/DeleteSavedSearch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) StreamSearchAlerts(ctx context.Context, in *StreamSearchAlertsRequest, opts ...grpc.CallOption) (LaptopService_StreamSearchAlertsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[6], "/LaptopService/StreamSearchAlerts", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceStreamSearchAlertsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LaptopService_StreamSearchAlertsClient interface {
+	Recv() (*SearchAlert, error)
+	grpc.ClientStream
+}
+
+type laptopServiceStreamSearchAlertsClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceStreamSearchAlertsClient) Recv() (*SearchAlert, error) {
+	m := new(SearchAlert)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) FindSimilarLaptops(ctx context.Context, in *FindSimilarLaptopsRequest, opts ...grpc.CallOption) (*FindSimilarLaptopsResponse, error) {
+	out := new(FindSimilarLaptopsResponse)
+	err := c.cc.Invoke(ctx, "/LaptopService/FindSimilarLaptops", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) ExplainSearch(ctx context.Context, in *ExplainSearchRequest, opts ...grpc.CallOption) (*ExplainSearchResponse, error) {
+	out := new(ExplainSearchResponse)
+	err := c.cc.Invoke(ctx, "/LaptopService/ExplainSearch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) ExportCatalog(ctx context.Context, in *ExportCatalogRequest, opts ...grpc.CallOption) (LaptopService_ExportCatalogClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[7], "/LaptopService/ExportCatalog", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceExportCatalogClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LaptopService_ExportCatalogClient interface {
+	Recv() (*ExportCatalogResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceExportCatalogClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceExportCatalogClient) Recv() (*ExportCatalogResponse, error) {
+	m := new(ExportCatalogResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) ImportCatalog(ctx context.Context, opts ...grpc.CallOption) (LaptopService_ImportCatalogClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[8], "/LaptopService/ImportCatalog", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceImportCatalogClient{stream}
+	return x, nil
+}
+
+type LaptopService_ImportCatalogClient interface {
+	Send(*ImportCatalogRequest) error
+	CloseAndRecv() (*ImportCatalogResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceImportCatalogClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceImportCatalogClient) Send(m *ImportCatalogRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *laptopServiceImportCatalogClient) CloseAndRecv() (*ImportCatalogResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportCatalogResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // LaptopServiceServer is the server API for LaptopService service.
 // All implementations must embed UnimplementedLaptopServiceServer
 // for forward compatibility
@@ -113,6 +457,24 @@ type LaptopServiceServer interface {
 	CreateLaptop(context.Context, *CreatelaptopRequest) (*CreateLaptopResponse, error)
 	SearchLaptop(*SearchLaptopRequest, LaptopService_SearchLaptopServer) error
 	UploadImage(LaptopService_UploadImageServer) error
+	GetLaptop(context.Context, *GetLaptopRequest) (*GetLaptopResponse, error)
+	UpdateLaptop(context.Context, *UpdateLaptopRequest) (*UpdateLaptopResponse, error)
+	DeleteLaptop(context.Context, *DeleteLaptopRequest) (*DeleteLaptopResponse, error)
+	ListDeletedLaptops(*ListDeletedLaptopsRequest, LaptopService_ListDeletedLaptopsServer) error
+	RestoreLaptop(context.Context, *RestoreLaptopRequest) (*RestoreLaptopResponse, error)
+	WatchLaptops(*WatchRequest, LaptopService_WatchLaptopsServer) error
+	ListLaptops(context.Context, *ListLaptopsRequest) (*ListLaptopsResponse, error)
+	RateLaptop(LaptopService_RateLaptopServer) error
+	CreateLaptops(LaptopService_CreateLaptopsServer) error
+	SearchFacets(context.Context, *SearchFacetsRequest) (*SearchFacetsResponse, error)
+	SaveSearch(context.Context, *SaveSearchRequest) (*SaveSearchResponse, error)
+	ListSavedSearches(context.Context, *ListSavedSearchesRequest) (*ListSavedSearchesResponse, error)
+	DeleteSavedSearch(context.Context, *DeleteSavedSearchRequest) (*DeleteSavedSearchResponse, error)
+	StreamSearchAlerts(*StreamSearchAlertsRequest, LaptopService_StreamSearchAlertsServer) error
+	FindSimilarLaptops(context.Context, *FindSimilarLaptopsRequest) (*FindSimilarLaptopsResponse, error)
+	ExplainSearch(context.Context, *ExplainSearchRequest) (*ExplainSearchResponse, error)
+	ExportCatalog(*ExportCatalogRequest, LaptopService_ExportCatalogServer) error
+	ImportCatalog(LaptopService_ImportCatalogServer) error
 	mustEmbedUnimplementedLaptopServiceServer()
 }
 
@@ -129,6 +491,60 @@ func (UnimplementedLaptopServiceServer) SearchLaptop(*SearchLaptopRequest, Lapto
 func (UnimplementedLaptopServiceServer) UploadImage(LaptopService_UploadImageServer) error {
 	return status.Errorf(codes.Unimplemented, "method UploadImage not implemented")
 }
+func (UnimplementedLaptopServiceServer) GetLaptop(context.Context, *GetLaptopRequest) (*GetLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) UpdateLaptop(context.Context, *UpdateLaptopRequest) (*UpdateLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) DeleteLaptop(context.Context, *DeleteLaptopRequest) (*DeleteLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) ListDeletedLaptops(*ListDeletedLaptopsRequest, LaptopService_ListDeletedLaptopsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListDeletedLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) RestoreLaptop(context.Context, *RestoreLaptopRequest) (*RestoreLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) WatchLaptops(*WatchRequest, LaptopService_WatchLaptopsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) ListLaptops(context.Context, *ListLaptopsRequest) (*ListLaptopsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) RateLaptop(LaptopService_RateLaptopServer) error {
+	return status.Errorf(codes.Unimplemented, "method RateLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) CreateLaptops(LaptopService_CreateLaptopsServer) error {
+	return status.Errorf(codes.Unimplemented, "method CreateLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) SearchFacets(context.Context, *SearchFacetsRequest) (*SearchFacetsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchFacets not implemented")
+}
+func (UnimplementedLaptopServiceServer) SaveSearch(context.Context, *SaveSearchRequest) (*SaveSearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveSearch not implemented")
+}
+func (UnimplementedLaptopServiceServer) ListSavedSearches(context.Context, *ListSavedSearchesRequest) (*ListSavedSearchesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSavedSearches not implemented")
+}
+func (UnimplementedLaptopServiceServer) DeleteSavedSearch(context.Context, *DeleteSavedSearchRequest) (*DeleteSavedSearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSavedSearch not implemented")
+}
+func (UnimplementedLaptopServiceServer) StreamSearchAlerts(*StreamSearchAlertsRequest, LaptopService_StreamSearchAlertsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSearchAlerts not implemented")
+}
+func (UnimplementedLaptopServiceServer) FindSimilarLaptops(context.Context, *FindSimilarLaptopsRequest) (*FindSimilarLaptopsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindSimilarLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) ExplainSearch(context.Context, *ExplainSearchRequest) (*ExplainSearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExplainSearch not implemented")
+}
+func (UnimplementedLaptopServiceServer) ExportCatalog(*ExportCatalogRequest, LaptopService_ExportCatalogServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportCatalog not implemented")
+}
+func (UnimplementedLaptopServiceServer) ImportCatalog(LaptopService_ImportCatalogServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportCatalog not implemented")
+}
 func (UnimplementedLaptopServiceServer) mustEmbedUnimplementedLaptopServiceServer() {}
 
 // UnsafeLaptopServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -207,17 +623,421 @@ func (x *laptopServiceUploadImageServer) Recv() (*UploadImageRequest, error) {
 	return m, nil
 }
 
-// LaptopService_ServiceDesc is the grpc.ServiceDesc for LaptopService service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
-var LaptopService_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "LaptopService",
-	HandlerType: (*LaptopServiceServer)(nil),
-	Methods: []grpc.MethodDesc{
+func _LaptopService_GetLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).GetLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/LaptopService/GetLaptop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).GetLaptop(ctx, req.(*GetLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_UpdateLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).UpdateLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/LaptopService/UpdateLaptop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).UpdateLaptop(ctx, req.(*UpdateLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_DeleteLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).DeleteLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/LaptopService/DeleteLaptop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).DeleteLaptop(ctx, req.(*DeleteLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_ListDeletedLaptops_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListDeletedLaptopsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LaptopServiceServer).ListDeletedLaptops(m, &laptopServiceListDeletedLaptopsServer{stream})
+}
+
+type LaptopService_ListDeletedLaptopsServer interface {
+	Send(*ListDeletedLaptopsResponse) error
+	grpc.ServerStream
+}
+
+type laptopServiceListDeletedLaptopsServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceListDeletedLaptopsServer) Send(m *ListDeletedLaptopsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LaptopService_RestoreLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).RestoreLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/LaptopService/RestoreLaptop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).RestoreLaptop(ctx, req.(*RestoreLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_WatchLaptops_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LaptopServiceServer).WatchLaptops(m, &laptopServiceWatchLaptopsServer{stream})
+}
+
+type LaptopService_WatchLaptopsServer interface {
+	Send(*LaptopEvent) error
+	grpc.ServerStream
+}
+
+type laptopServiceWatchLaptopsServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceWatchLaptopsServer) Send(m *LaptopEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LaptopService_ListLaptops_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLaptopsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).ListLaptops(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/LaptopService/ListLaptops",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).ListLaptops(ctx, req.(*ListLaptopsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_RateLaptop_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LaptopServiceServer).RateLaptop(&laptopServiceRateLaptopServer{stream})
+}
+
+type LaptopService_RateLaptopServer interface {
+	Send(*RateLaptopResponse) error
+	Recv() (*RateLaptopRequest, error)
+	grpc.ServerStream
+}
+
+type laptopServiceRateLaptopServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceRateLaptopServer) Send(m *RateLaptopResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *laptopServiceRateLaptopServer) Recv() (*RateLaptopRequest, error) {
+	m := new(RateLaptopRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _LaptopService_CreateLaptops_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LaptopServiceServer).CreateLaptops(&laptopServiceCreateLaptopsServer{stream})
+}
+
+type LaptopService_CreateLaptopsServer interface {
+	SendAndClose(*CreateLaptopsResponse) error
+	Recv() (*CreateLaptopsRequest, error)
+	grpc.ServerStream
+}
+
+type laptopServiceCreateLaptopsServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceCreateLaptopsServer) SendAndClose(m *CreateLaptopsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *laptopServiceCreateLaptopsServer) Recv() (*CreateLaptopsRequest, error) {
+	m := new(CreateLaptopsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _LaptopService_SearchFacets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchFacetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).SearchFacets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/LaptopService/SearchFacets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).SearchFacets(ctx, req.(*SearchFacetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_SaveSearch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SaveSearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).SaveSearch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/LaptopService/SaveSearch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).SaveSearch(ctx, req.(*SaveSearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_ListSavedSearches_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSavedSearchesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).ListSavedSearches(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/LaptopService/ListSavedSearches",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).ListSavedSearches(ctx, req.(*ListSavedSearchesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_DeleteSavedSearch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSavedSearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).DeleteSavedSearch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/LaptopService/DeleteSavedSearch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).DeleteSavedSearch(ctx, req.(*DeleteSavedSearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_StreamSearchAlerts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamSearchAlertsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LaptopServiceServer).StreamSearchAlerts(m, &laptopServiceStreamSearchAlertsServer{stream})
+}
+
+type LaptopService_StreamSearchAlertsServer interface {
+	Send(*SearchAlert) error
+	grpc.ServerStream
+}
+
+type laptopServiceStreamSearchAlertsServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceStreamSearchAlertsServer) Send(m *SearchAlert) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LaptopService_FindSimilarLaptops_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindSimilarLaptopsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).FindSimilarLaptops(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/LaptopService/FindSimilarLaptops",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).FindSimilarLaptops(ctx, req.(*FindSimilarLaptopsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_ExplainSearch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExplainSearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).ExplainSearch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/LaptopService/ExplainSearch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).ExplainSearch(ctx, req.(*ExplainSearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_ExportCatalog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportCatalogRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LaptopServiceServer).ExportCatalog(m, &laptopServiceExportCatalogServer{stream})
+}
+
+type LaptopService_ExportCatalogServer interface {
+	Send(*ExportCatalogResponse) error
+	grpc.ServerStream
+}
+
+type laptopServiceExportCatalogServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceExportCatalogServer) Send(m *ExportCatalogResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LaptopService_ImportCatalog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LaptopServiceServer).ImportCatalog(&laptopServiceImportCatalogServer{stream})
+}
+
+type LaptopService_ImportCatalogServer interface {
+	SendAndClose(*ImportCatalogResponse) error
+	Recv() (*ImportCatalogRequest, error)
+	grpc.ServerStream
+}
+
+type laptopServiceImportCatalogServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceImportCatalogServer) SendAndClose(m *ImportCatalogResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *laptopServiceImportCatalogServer) Recv() (*ImportCatalogRequest, error) {
+	m := new(ImportCatalogRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LaptopService_ServiceDesc is the grpc.ServiceDesc for LaptopService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LaptopService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "LaptopService",
+	HandlerType: (*LaptopServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
 		{
 			MethodName: "CreateLaptop",
 			Handler:    _LaptopService_CreateLaptop_Handler,
 		},
+		{
+			MethodName: "GetLaptop",
+			Handler:    _LaptopService_GetLaptop_Handler,
+		},
+		{
+			MethodName: "UpdateLaptop",
+			Handler:    _LaptopService_UpdateLaptop_Handler,
+		},
+		{
+			MethodName: "DeleteLaptop",
+			Handler:    _LaptopService_DeleteLaptop_Handler,
+		},
+		{
+			MethodName: "RestoreLaptop",
+			Handler:    _LaptopService_RestoreLaptop_Handler,
+		},
+		{
+			MethodName: "ListLaptops",
+			Handler:    _LaptopService_ListLaptops_Handler,
+		},
+		{
+			MethodName: "SearchFacets",
+			Handler:    _LaptopService_SearchFacets_Handler,
+		},
+		{
+			MethodName: "SaveSearch",
+			Handler:    _LaptopService_SaveSearch_Handler,
+		},
+		{
+			MethodName: "ListSavedSearches",
+			Handler:    _LaptopService_ListSavedSearches_Handler,
+		},
+		{
+			MethodName: "DeleteSavedSearch",
+			Handler:    _LaptopService_DeleteSavedSearch_Handler,
+		},
+		{
+			MethodName: "FindSimilarLaptops",
+			Handler:    _LaptopService_FindSimilarLaptops_Handler,
+		},
+		{
+			MethodName: "ExplainSearch",
+			Handler:    _LaptopService_ExplainSearch_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -230,6 +1050,42 @@ var LaptopService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _LaptopService_UploadImage_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "ListDeletedLaptops",
+			Handler:       _LaptopService_ListDeletedLaptops_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchLaptops",
+			Handler:       _LaptopService_WatchLaptops_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "RateLaptop",
+			Handler:       _LaptopService_RateLaptop_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "CreateLaptops",
+			Handler:       _LaptopService_CreateLaptops_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "StreamSearchAlerts",
+			Handler:       _LaptopService_StreamSearchAlerts_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportCatalog",
+			Handler:       _LaptopService_ExportCatalog_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportCatalog",
+			Handler:       _LaptopService_ImportCatalog_Handler,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "laptop_service.proto",
 }
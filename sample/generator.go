@@ -127,4 +127,8 @@ func NewLaptop() *pb.Laptop {
 	}
 
 	return laptop
-}
\ No newline at end of file
+}
+
+func RandomLaptopScore() float64 {
+	return float64(randomInt(1, 10))
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"container/list"
+	"github.com/Adetunjii/go-grpc/pb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"sync"
+	"time"
+)
+
+// DefaultCacheCapacity is the number of laptops a CachedLaptopStore keeps when no capacity is given
+const DefaultCacheCapacity = 1024
+
+// CacheStats counts how FindById calls on a CachedLaptopStore were answered
+type CacheStats struct {
+	Hits   uint64 // answered from the cache
+	Misses uint64 // not in the cache, including the calls waiting for another one to load the laptop
+	Loads  uint64 // calls made to the wrapped store, at most one at a time per laptop
+}
+
+// CachedLaptopStore caches the results of FindById of the wrapped store in a size-bounded LRU.
+// Laptops that don't exist are cached as well. Every write invalidates the laptops it changes,
+// and concurrent misses for the same laptop wait for a single load from the wrapped store.
+// Other reads are passed through.
+type CachedLaptopStore struct {
+	LaptopStore
+
+	mutex    sync.Mutex
+	capacity int
+	entries  map[string]*list.Element // laptop id -> element of order holding a *cacheEntry
+	order    *list.List               // most recently used first
+	loads    map[string]*laptopLoad   // loads in flight by laptop id
+	stats    CacheStats
+}
+
+type cacheEntry struct {
+	id     string
+	laptop *pb.Laptop // nil if the laptop doesn't exist, never modified
+}
+
+// laptopLoad is a FindById call on the wrapped store that other misses wait for
+type laptopLoad struct {
+	done   chan struct{}
+	laptop *pb.Laptop
+	err    error
+}
+
+func NewCachedLaptopStore(store LaptopStore, capacity int) *CachedLaptopStore {
+	if capacity <= 0 {
+		capacity = DefaultCacheCapacity
+	}
+
+	return &CachedLaptopStore{
+		LaptopStore: store,
+		capacity:    capacity,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+		loads:       make(map[string]*laptopLoad),
+	}
+}
+
+func (store *CachedLaptopStore) FindById(id string) (*pb.Laptop, error) {
+	store.mutex.Lock()
+
+	if element, ok := store.entries[id]; ok {
+		store.order.MoveToFront(element)
+		store.stats.Hits++
+		laptop := element.Value.(*cacheEntry).laptop
+		store.mutex.Unlock()
+
+		return copyCachedLaptop(laptop)
+	}
+
+	store.stats.Misses++
+
+	if load := store.loads[id]; load != nil {
+		store.mutex.Unlock()
+
+		<-load.done
+		if load.err != nil {
+			return nil, load.err
+		}
+		return copyCachedLaptop(load.laptop)
+	}
+
+	load := &laptopLoad{done: make(chan struct{})}
+	store.loads[id] = load
+	store.stats.Loads++
+	store.mutex.Unlock()
+
+	load.laptop, load.err = store.LaptopStore.FindById(id)
+
+	store.mutex.Lock()
+	// a write invalidating the laptop while loading removes the load, its result may be stale then
+	if store.loads[id] == load {
+		delete(store.loads, id)
+		if load.err == nil {
+			store.add(id, load.laptop)
+		}
+	}
+	store.mutex.Unlock()
+	close(load.done)
+
+	if load.err != nil {
+		return nil, load.err
+	}
+	return copyCachedLaptop(load.laptop)
+}
+
+// add caches the laptop and evicts the least recently used one if the cache is full,
+// it must be called with the mutex held
+func (store *CachedLaptopStore) add(id string, laptop *pb.Laptop) {
+	store.entries[id] = store.order.PushFront(&cacheEntry{id: id, laptop: laptop})
+
+	if store.order.Len() > store.capacity {
+		oldest := store.order.Back()
+		store.order.Remove(oldest)
+		delete(store.entries, oldest.Value.(*cacheEntry).id)
+	}
+}
+
+// invalidate drops the cached laptops and the loads in flight for them
+func (store *CachedLaptopStore) invalidate(ids ...string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, id := range ids {
+		if element, ok := store.entries[id]; ok {
+			store.order.Remove(element)
+			delete(store.entries, id)
+		}
+		delete(store.loads, id)
+	}
+}
+
+// Stats returns the counters since the store was created
+func (store *CachedLaptopStore) Stats() CacheStats {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.stats
+}
+
+// Len returns the number of cached laptops
+func (store *CachedLaptopStore) Len() int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.order.Len()
+}
+
+func (store *CachedLaptopStore) Save(laptop *pb.Laptop) error {
+	// a missing laptop may have been cached
+	defer store.invalidate(laptop.GetId())
+	return store.LaptopStore.Save(laptop)
+}
+
+func (store *CachedLaptopStore) Update(laptop *pb.Laptop, mask *fieldmaskpb.FieldMask, expectedVersion uint64) (*pb.Laptop, error) {
+	defer store.invalidate(laptop.GetId())
+	return store.LaptopStore.Update(laptop, mask, expectedVersion)
+}
+
+func (store *CachedLaptopStore) Delete(id string, expectedVersion uint64) error {
+	defer store.invalidate(id)
+	return store.LaptopStore.Delete(id, expectedVersion)
+}
+
+func (store *CachedLaptopStore) Restore(id string, expectedVersion uint64) (*pb.Laptop, error) {
+	defer store.invalidate(id)
+	return store.LaptopStore.Restore(id, expectedVersion)
+}
+
+func (store *CachedLaptopStore) Purge(deletedBefore time.Time) ([]string, error) {
+	purged, err := store.LaptopStore.Purge(deletedBefore)
+	store.invalidate(purged...)
+	return purged, err
+}
+
+// copyCachedLaptop returns a copy of a cached laptop, which may be nil
+func copyCachedLaptop(laptop *pb.Laptop) (*pb.Laptop, error) {
+	if laptop == nil {
+		return nil, nil
+	}
+
+	return deepCopy(laptop)
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/sample"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCachedLaptopStore_FindById(t *testing.T) {
+	t.Parallel()
+
+	store := NewCachedLaptopStore(NewInMemoryLaptopStore(), 2)
+
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop(), sample.NewLaptop()}
+	for _, laptop := range laptops {
+		require.NoError(t, store.Save(laptop))
+	}
+
+	found, err := store.FindById(laptops[0].Id)
+	require.NoError(t, err)
+	require.Equal(t, laptops[0].Id, found.GetId())
+
+	// changing the returned laptop doesn't change the cached one
+	found.PriceUsd = -1
+	found, err = store.FindById(laptops[0].Id)
+	require.NoError(t, err)
+	require.Equal(t, laptops[0].PriceUsd, found.GetPriceUsd())
+	require.Equal(t, CacheStats{Hits: 1, Misses: 1, Loads: 1}, store.Stats())
+
+	// the least recently used laptop is evicted
+	_, err = store.FindById(laptops[1].Id)
+	require.NoError(t, err)
+	_, err = store.FindById(laptops[0].Id)
+	require.NoError(t, err)
+	_, err = store.FindById(laptops[2].Id)
+	require.NoError(t, err)
+	require.Equal(t, 2, store.Len())
+
+	_, err = store.FindById(laptops[0].Id)
+	require.NoError(t, err)
+	_, err = store.FindById(laptops[1].Id)
+	require.NoError(t, err)
+	require.Equal(t, CacheStats{Hits: 3, Misses: 4, Loads: 4}, store.Stats())
+
+	// missing laptops are cached too
+	missing := sample.NewLaptop()
+	found, err = store.FindById(missing.Id)
+	require.NoError(t, err)
+	require.Nil(t, found)
+	found, err = store.FindById(missing.Id)
+	require.NoError(t, err)
+	require.Nil(t, found)
+	require.Equal(t, CacheStats{Hits: 4, Misses: 5, Loads: 5}, store.Stats())
+}
+
+func TestCachedLaptopStore_WritesInvalidate(t *testing.T) {
+	t.Parallel()
+
+	store := NewCachedLaptopStore(NewInMemoryLaptopStore(), 0)
+	laptop := sample.NewLaptop()
+
+	find := func() *pb.Laptop {
+		found, err := store.FindById(laptop.Id)
+		require.NoError(t, err)
+		return found
+	}
+
+	require.Nil(t, find())
+
+	require.NoError(t, store.Save(laptop))
+	require.NotNil(t, find())
+
+	_, err := store.Update(&pb.Laptop{Id: laptop.Id, PriceUsd: 123}, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 0)
+	require.NoError(t, err)
+	require.Equal(t, float64(123), find().GetPriceUsd())
+
+	require.NoError(t, store.Delete(laptop.Id, 0))
+	require.Nil(t, find())
+
+	_, err = store.Restore(laptop.Id, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, find().GetVersion())
+
+	require.NoError(t, store.Delete(laptop.Id, 0))
+	require.Nil(t, find())
+	purged, err := store.Purge(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, []string{laptop.Id}, purged)
+	require.Zero(t, store.Len())
+}
+
+// slowLaptopStore finds the laptop then blocks until released, and counts the calls
+type slowLaptopStore struct {
+	*InMemoryLaptopStore
+	release chan struct{}
+
+	mutex sync.Mutex
+	calls int
+}
+
+func (store *slowLaptopStore) FindById(id string) (*pb.Laptop, error) {
+	laptop, err := store.InMemoryLaptopStore.FindById(id)
+
+	store.mutex.Lock()
+	store.calls++
+	store.mutex.Unlock()
+
+	<-store.release
+	return laptop, err
+}
+
+func (store *slowLaptopStore) callCount() int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.calls
+}
+
+func TestCachedLaptopStore_ConcurrentMissesLoadOnce(t *testing.T) {
+	t.Parallel()
+
+	backend := &slowLaptopStore{InMemoryLaptopStore: NewInMemoryLaptopStore(), release: make(chan struct{})}
+	store := NewCachedLaptopStore(backend, 0)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+
+	const readers = 20
+	wg := sync.WaitGroup{}
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			found, err := store.FindById(laptop.Id)
+			assert.NoError(t, err)
+			assert.Equal(t, laptop.Id, found.GetId())
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return store.Stats().Misses == readers
+	}, time.Second, time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+
+	require.Equal(t, 1, backend.callCount())
+	require.Equal(t, CacheStats{Misses: readers, Loads: 1}, store.Stats())
+}
+
+func TestCachedLaptopStore_WriteDuringLoad(t *testing.T) {
+	t.Parallel()
+
+	backend := &slowLaptopStore{InMemoryLaptopStore: NewInMemoryLaptopStore(), release: make(chan struct{})}
+	store := NewCachedLaptopStore(backend, 0)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := store.FindById(laptop.Id)
+		assert.NoError(t, err)
+	}()
+
+	require.Eventually(t, func() bool {
+		return backend.callCount() == 1
+	}, time.Second, time.Millisecond)
+
+	// the load read the laptop before the update, so its result must not be cached
+	_, err := store.Update(&pb.Laptop{Id: laptop.Id, PriceUsd: 123}, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 0)
+	require.NoError(t, err)
+	close(backend.release)
+	<-done
+
+	found, err := store.FindById(laptop.Id)
+	require.NoError(t, err)
+	require.Equal(t, float64(123), found.GetPriceUsd())
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"fmt"
+	"github.com/Adetunjii/go-grpc/pb"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"strings"
+)
+
+// fields that can never be changed through an update mask
+var immutableLaptopPaths = map[string]bool{
+	"id":         true,
+	"updated_at": true,
+	"version":    true,
+	"deleted_at": true,
+}
+
+// validateLaptopMask checks that every path in the mask names a mutable field of pb.Laptop
+func validateLaptopMask(mask *fieldmaskpb.FieldMask) error {
+	for _, path := range mask.GetPaths() {
+		if immutableLaptopPaths[path] {
+			return fmt.Errorf("field %q cannot be updated", path)
+		}
+
+		if _, err := fieldmaskpb.New(&pb.Laptop{}, path); err != nil {
+			return fmt.Errorf("invalid update mask path %q", path)
+		}
+	}
+
+	return nil
+}
+
+// applyLaptopMask copies the masked fields of src into dst.
+// An empty mask replaces every mutable field.
+func applyLaptopMask(dst *pb.Laptop, src *pb.Laptop, mask *fieldmaskpb.FieldMask) {
+	paths := mask.GetPaths()
+	if len(paths) == 0 {
+		fields := dst.ProtoReflect().Descriptor().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			name := string(fields.Get(i).Name())
+			if !immutableLaptopPaths[name] {
+				paths = append(paths, name)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		copyPath(dst.ProtoReflect(), src.ProtoReflect(), strings.Split(path, "."))
+	}
+}
+
+func copyPath(dst protoreflect.Message, src protoreflect.Message, path []string) {
+	field := dst.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+
+	if len(path) == 1 {
+		if src.Has(field) {
+			dst.Set(field, src.Get(field))
+		} else {
+			dst.Clear(field)
+		}
+		return
+	}
+
+	copyPath(dst.Mutable(field).Message(), src.Get(field).Message(), path[1:])
+}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/Adetunjii/go-grpc/pb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	laptopLogFile      = "laptops.wal"
+	laptopSnapshotFile = "laptops.snapshot"
+
+	// DefaultSnapshotInterval is the number of log records after which the log is compacted into a snapshot
+	DefaultSnapshotInterval = 1000
+)
+
+// FileLaptopStore keeps the laptops in memory like InMemoryLaptopStore and persists them in a folder.
+// Every change is appended to a write-ahead log and synced before it is applied in memory,
+// so a change that cannot be logged is never seen by readers or watchers.
+// The log is regularly compacted into a snapshot of the whole catalog.
+// Opening the store loads the snapshot, then replays the log written after it.
+type FileLaptopStore struct {
+	*InMemoryLaptopStore
+
+	// held from a change until the snapshot it may trigger is taken,
+	// so that no change is logged between writing a snapshot and emptying the log
+	writeMutex       sync.Mutex
+	folder           string
+	log              logFile
+	logSize          int64 // size of the synced records, the log is truncated back to it after a failed write
+	logRecords       int   // records appended since the last snapshot
+	snapshotInterval int
+
+	// set when a failed write couldn't be rolled back, every later change is refused
+	// since the log may end with a partial record that further records would follow
+	logFailure error
+}
+
+// logFile is the part of *os.File the log is written with
+type logFile interface {
+	io.Writer
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+func NewFileLaptopStore(folder string, snapshotInterval int) (*FileLaptopStore, error) {
+	if snapshotInterval <= 0 {
+		snapshotInterval = DefaultSnapshotInterval
+	}
+
+	err := os.MkdirAll(folder, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create laptop store folder: %v", err)
+	}
+
+	store := &FileLaptopStore{
+		InMemoryLaptopStore: NewInMemoryLaptopStore(),
+		folder:              folder,
+		snapshotInterval:    snapshotInterval,
+	}
+
+	err = store.loadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	err = store.replayLog()
+	if err != nil {
+		return nil, err
+	}
+
+	store.writeAhead = store.appendLog
+	return store, nil
+}
+
+func (store *FileLaptopStore) loadSnapshot() error {
+	file, err := os.Open(filepath.Join(store.folder, laptopSnapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot open laptop snapshot: %v", err)
+	}
+	defer file.Close()
+
+	// snapshots are renamed into place once complete, so even their last record must be whole
+	_, torn, err := readRecords(file, store.apply)
+	if err == nil && torn {
+		err = fmt.Errorf("%w: snapshot ends with a torn record", CorruptLogException)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot load laptop snapshot: %w", err)
+	}
+
+	return nil
+}
+
+func (store *FileLaptopStore) replayLog() error {
+	file, err := os.OpenFile(filepath.Join(store.folder, laptopLogFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open laptop log: %v", err)
+	}
+
+	size, torn, err := readRecords(file, func(record *pb.LaptopLogRecord) error {
+		store.logRecords++
+		return store.apply(record)
+	})
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("cannot replay laptop log: %w", err)
+	}
+
+	if torn {
+		// the last write was interrupted, so the change it carried was never acknowledged
+		log.Printf("skipping the torn record at the end of the laptop log, at offset %d", size)
+
+		err = file.Truncate(size)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("cannot truncate laptop log: %v", err)
+		}
+	}
+
+	store.log = file
+	store.logSize = size
+	return nil
+}
+
+// apply replays a record of the log or the snapshot on the laptops in memory
+func (store *FileLaptopStore) apply(record *pb.LaptopLogRecord) error {
+	switch change := record.GetChange().(type) {
+	case *pb.LaptopLogRecord_Put:
+		return store.load(change.Put)
+	case *pb.LaptopLogRecord_RemovedId:
+		store.remove(change.RemovedId)
+		return nil
+	default:
+		return fmt.Errorf("%w: record without any change", CorruptLogException)
+	}
+}
+
+func (store *FileLaptopStore) Save(laptop *pb.Laptop) error {
+	store.writeMutex.Lock()
+	defer store.writeMutex.Unlock()
+	defer store.snapshotIfDue()
+
+	return store.InMemoryLaptopStore.Save(laptop)
+}
+
+func (store *FileLaptopStore) Update(laptop *pb.Laptop, mask *fieldmaskpb.FieldMask, expectedVersion uint64) (*pb.Laptop, error) {
+	store.writeMutex.Lock()
+	defer store.writeMutex.Unlock()
+	defer store.snapshotIfDue()
+
+	return store.InMemoryLaptopStore.Update(laptop, mask, expectedVersion)
+}
+
+func (store *FileLaptopStore) Delete(id string, expectedVersion uint64) error {
+	store.writeMutex.Lock()
+	defer store.writeMutex.Unlock()
+	defer store.snapshotIfDue()
+
+	return store.InMemoryLaptopStore.Delete(id, expectedVersion)
+}
+
+func (store *FileLaptopStore) Restore(id string, expectedVersion uint64) (*pb.Laptop, error) {
+	store.writeMutex.Lock()
+	defer store.writeMutex.Unlock()
+	defer store.snapshotIfDue()
+
+	return store.InMemoryLaptopStore.Restore(id, expectedVersion)
+}
+
+func (store *FileLaptopStore) Purge(deletedBefore time.Time) ([]string, error) {
+	store.writeMutex.Lock()
+	defer store.writeMutex.Unlock()
+	defer store.snapshotIfDue()
+
+	return store.InMemoryLaptopStore.Purge(deletedBefore)
+}
+
+// appendLog writes the records of a change and syncs the log. A failed write is truncated away
+// so that the log stays readable, and the change must then be dropped.
+// It is called by the in-memory store with its mutex held, before applying the change.
+func (store *FileLaptopStore) appendLog(records ...*pb.LaptopLogRecord) error {
+	if store.logFailure != nil {
+		return fmt.Errorf("laptop log is unusable after a failed write: %v", store.logFailure)
+	}
+
+	frames := bytes.Buffer{}
+	for _, record := range records {
+		err := appendRecord(&frames, record)
+		if err != nil {
+			return fmt.Errorf("cannot write to laptop log: %v", err)
+		}
+	}
+
+	_, err := store.log.Write(frames.Bytes())
+	if err == nil {
+		err = store.log.Sync()
+	}
+	if err != nil {
+		store.rollbackLog()
+		return fmt.Errorf("cannot write to laptop log: %v", err)
+	}
+
+	store.logSize += int64(frames.Len())
+	store.logRecords += len(records)
+	return nil
+}
+
+// rollbackLog removes whatever part of a failed write reached the log
+func (store *FileLaptopStore) rollbackLog() {
+	err := store.log.Truncate(store.logSize)
+	if err == nil {
+		err = store.log.Sync()
+	}
+
+	if err != nil {
+		log.Print("cannot roll back the laptop log, refusing every change from now on: ", err)
+		store.logFailure = err
+	}
+}
+
+// snapshotIfDue takes a snapshot if enough records were logged, it must be called with the write mutex held
+func (store *FileLaptopStore) snapshotIfDue() {
+	if store.logRecords < store.snapshotInterval || store.logFailure != nil {
+		return
+	}
+
+	// the changes are already durable in the log, so a failed snapshot is retried after the next change
+	err := store.snapshot()
+	if err != nil {
+		log.Print("cannot snapshot laptops: ", err)
+	}
+}
+
+// snapshot writes every stored laptop to a new snapshot which replaces the previous one, then empties the log.
+// Replaying the log over the snapshot gives the same laptops, so a crash in between loses nothing.
+// It must be called with the write mutex held.
+func (store *FileLaptopStore) snapshot() error {
+	path := filepath.Join(store.folder, laptopSnapshotFile)
+	tmpPath := path + ".tmp"
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("cannot create snapshot file: %v", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, laptop := range store.all() {
+		err = appendRecord(writer, putRecord(laptop))
+		if err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("cannot write snapshot file: %v", err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return fmt.Errorf("cannot replace snapshot file: %v", err)
+	}
+
+	err = syncFolder(store.folder)
+	if err != nil {
+		return err
+	}
+
+	err = store.log.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("cannot truncate laptop log: %v", err)
+	}
+
+	store.logSize = 0
+	store.logRecords = 0
+	return store.log.Sync()
+}
+
+// Close closes the log, the store must not be changed afterwards
+func (store *FileLaptopStore) Close() error {
+	store.writeMutex.Lock()
+	defer store.writeMutex.Unlock()
+
+	return store.log.Close()
+}
+
+// syncFolder makes a rename inside the folder durable
+func syncFolder(folder string) error {
+	dir, err := os.Open(folder)
+	if err != nil {
+		return fmt.Errorf("cannot open folder: %v", err)
+	}
+	defer dir.Close()
+
+	err = dir.Sync()
+	if err != nil {
+		return fmt.Errorf("cannot sync folder: %v", err)
+	}
+
+	return nil
+}
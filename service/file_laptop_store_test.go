@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestFileLaptopStore(t *testing.T, folder string, snapshotInterval int) *FileLaptopStore {
+	store, err := NewFileLaptopStore(folder, snapshotInterval)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+// requireSameLaptops checks that both stores hold the same laptops, deleted ones included
+func requireSameLaptops(t *testing.T, expected *FileLaptopStore, actual *FileLaptopStore) {
+	want := expected.all()
+	got := actual.all()
+	require.Len(t, got, len(want))
+
+	for i := range want {
+		require.True(t, proto.Equal(want[i], got[i]), "laptop %s differs after reopening", want[i].GetId())
+	}
+}
+
+func TestFileLaptopStore_Reopen(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+	store := newTestFileLaptopStore(t, folder, 0)
+
+	laptops := make([]*pb.Laptop, 4)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+		require.NoError(t, store.Save(laptops[i]))
+	}
+
+	_, err := store.Update(&pb.Laptop{Id: laptops[0].Id, PriceUsd: 999}, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 1)
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(laptops[1].Id, 0))
+	require.NoError(t, store.Delete(laptops[2].Id, 0))
+	_, err = store.Restore(laptops[2].Id, 0)
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(laptops[3].Id, 0))
+
+	purged, err := store.Purge(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{laptops[1].Id, laptops[3].Id}, purged)
+	require.NoError(t, store.Close())
+
+	reopened := newTestFileLaptopStore(t, folder, 0)
+	requireSameLaptops(t, store, reopened)
+
+	updated, err := reopened.FindById(laptops[0].Id)
+	require.NoError(t, err)
+	require.Equal(t, float64(999), updated.GetPriceUsd())
+	require.EqualValues(t, 2, updated.GetVersion())
+
+	restored, err := reopened.FindById(laptops[2].Id)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, restored.GetVersion())
+
+	// the indexes are rebuilt as well
+	require.Equal(t, []string{laptops[0].Id}, searchIds(t, reopened.InMemoryLaptopStore, &pb.Filter{MaxPriceUsd: 1000}, true))
+
+	require.Equal(t, DuplicateException, reopened.Save(laptops[0]))
+	_, err = reopened.Update(&pb.Laptop{Id: laptops[0].Id}, nil, 1)
+	require.Equal(t, VersionMismatchException, err)
+}
+
+func TestFileLaptopStore_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+	store := newTestFileLaptopStore(t, folder, 3)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.Save(sample.NewLaptop()))
+	}
+
+	// the first three records were compacted into the snapshot
+	records := 0
+	file, err := os.Open(filepath.Join(folder, laptopLogFile))
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, torn, err := readRecords(file, func(record *pb.LaptopLogRecord) error {
+		records++
+		return nil
+	})
+	require.NoError(t, err)
+	require.False(t, torn)
+	require.Equal(t, 2, records)
+
+	_, err = os.Stat(filepath.Join(folder, laptopSnapshotFile))
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	reopened := newTestFileLaptopStore(t, folder, 3)
+	requireSameLaptops(t, store, reopened)
+	require.Len(t, reopened.all(), 5)
+}
+
+func TestFileLaptopStore_TornTail(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+	store := newTestFileLaptopStore(t, folder, 0)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+	require.NoError(t, store.Close())
+
+	logPath := filepath.Join(folder, laptopLogFile)
+	info, err := os.Stat(logPath)
+	require.NoError(t, err)
+	validSize := info.Size()
+
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		tail []byte
+	}{
+		{
+			name: "partial_header",
+			tail: data[:recordHeaderSize/2],
+		},
+		{
+			name: "partial_record",
+			tail: data[:len(data)-1],
+		},
+		{
+			name: "bad_checksum",
+			tail: append(append([]byte{}, data[:len(data)-1]...), data[len(data)-1]^0xff),
+		},
+	}
+
+	for _, tc := range testCases {
+		file, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0644)
+		require.NoError(t, err)
+		_, err = file.Write(tc.tail)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		reopened, err := NewFileLaptopStore(folder, 0)
+		require.NoError(t, err, tc.name)
+		require.Len(t, reopened.all(), 1, tc.name)
+
+		// the torn record is cut off, so the log is valid again
+		info, err := os.Stat(logPath)
+		require.NoError(t, err)
+		require.Equal(t, validSize, info.Size(), tc.name)
+		require.NoError(t, reopened.Close())
+	}
+
+	reopened := newTestFileLaptopStore(t, folder, 0)
+	other := sample.NewLaptop()
+	require.NoError(t, reopened.Save(other))
+	require.NoError(t, reopened.Close())
+
+	reopened = newTestFileLaptopStore(t, folder, 0)
+	require.Len(t, reopened.all(), 2)
+}
+
+func TestFileLaptopStore_CorruptLog(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+	store := newTestFileLaptopStore(t, folder, 0)
+
+	require.NoError(t, store.Save(sample.NewLaptop()))
+	require.NoError(t, store.Save(sample.NewLaptop()))
+	require.NoError(t, store.Close())
+
+	logPath := filepath.Join(folder, laptopLogFile)
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+
+	// damaging the first record is not a torn tail since a whole record follows it
+	data[recordHeaderSize] ^= 0xff
+	require.NoError(t, os.WriteFile(logPath, data, 0644))
+
+	_, err = NewFileLaptopStore(folder, 0)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, CorruptLogException))
+}
+
+// failingLogFile writes half of the data then fails for the given number of writes,
+// and fails every truncation if failTruncate is set
+type failingLogFile struct {
+	logFile
+	failures     int
+	failTruncate bool
+}
+
+func (file *failingLogFile) Write(data []byte) (int, error) {
+	if file.failures == 0 {
+		return file.logFile.Write(data)
+	}
+
+	file.failures--
+	n, _ := file.logFile.Write(data[:len(data)/2])
+	return n, errors.New("disk full")
+}
+
+func (file *failingLogFile) Truncate(size int64) error {
+	if file.failTruncate {
+		return errors.New("disk failure")
+	}
+	return file.logFile.Truncate(size)
+}
+
+func TestFileLaptopStore_WriteFailure(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		failTruncate bool
+	}{
+		{
+			name: "rolled_back",
+		},
+		{
+			// the partial record can't be removed, so the store refuses every later change
+			name:         "read_only",
+			failTruncate: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			folder := t.TempDir()
+			store := newTestFileLaptopStore(t, folder, 0)
+
+			saved := sample.NewLaptop()
+			require.NoError(t, store.Save(saved))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := store.Watch(ctx)
+
+			store.log = &failingLogFile{logFile: store.log, failures: 1, failTruncate: tc.failTruncate}
+
+			// the change that couldn't be logged is neither visible nor published
+			failed := sample.NewLaptop()
+			require.Error(t, store.Save(failed))
+			found, err := store.FindById(failed.Id)
+			require.NoError(t, err)
+			require.Nil(t, found)
+
+			_, err = store.Update(&pb.Laptop{Id: saved.Id, PriceUsd: 999}, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 0)
+			if tc.failTruncate {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			published := []pb.LaptopEvent_Type{}
+			for len(events) > 0 {
+				published = append(published, (<-events).GetType())
+			}
+
+			require.NoError(t, store.Close())
+
+			// the log stays readable whether or not the partial record could be removed
+			reopened := newTestFileLaptopStore(t, folder, 0)
+			requireSameLaptops(t, store, reopened)
+
+			found, err = reopened.FindById(failed.Id)
+			require.NoError(t, err)
+			require.Nil(t, found)
+
+			found, err = reopened.FindById(saved.Id)
+			require.NoError(t, err)
+			if tc.failTruncate {
+				require.Empty(t, published)
+				require.Equal(t, saved.PriceUsd, found.GetPriceUsd())
+			} else {
+				require.Equal(t, []pb.LaptopEvent_Type{pb.LaptopEvent_UPDATED}, published)
+				require.Equal(t, float64(999), found.GetPriceUsd())
+			}
+		})
+	}
+}
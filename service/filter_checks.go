@@ -0,0 +1,132 @@
+package service
+
+import (
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/units"
+)
+
+// filterCheck tests a laptop against one criterion of a filter,
+// a criterion left to its zero value always passes.
+// A check only fails on a definite violation, so incomparable NaN values pass.
+type filterCheck struct {
+	criterion string // name of the filter field
+	passes    func(filter *pb.Filter, laptop *pb.Laptop) bool
+}
+
+var filterChecks = []filterCheck{
+	{
+		criterion: "max_price_usd",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return !(filter.GetMaxPriceUsd() > 0 && laptop.GetPriceUsd() > filter.GetMaxPriceUsd())
+		},
+	},
+	{
+		criterion: "min_cpu_cores",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return !(laptop.GetCpu().GetNumberOfCores() < filter.GetMinCpuCores())
+		},
+	},
+	{
+		criterion: "min_cup_ghb",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return !(laptop.GetCpu().GetMinGhz() < filter.GetMinCupGhb())
+		},
+	},
+	{
+		criterion: "min_ram",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return !(units.MemoryBits(laptop.GetRam()) < units.MemoryBits(filter.GetMinRam()))
+		},
+	},
+	{
+		criterion: "brands",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return len(filter.GetBrands()) == 0 || containsFold(filter.GetBrands(), laptop.GetBrand())
+		},
+	},
+	{
+		criterion: "min_release_year",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return !(laptop.GetReleaseYear() < filter.GetMinReleaseYear())
+		},
+	},
+	{
+		criterion: "max_release_year",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return !(filter.GetMaxReleaseYear() > 0 && laptop.GetReleaseYear() > filter.GetMaxReleaseYear())
+		},
+	},
+	{
+		criterion: "min_screen_size_inch",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return !(laptop.GetScreen().GetSizeInch() < filter.GetMinScreenSizeInch())
+		},
+	},
+	{
+		criterion: "max_screen_size_inch",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return !(filter.GetMaxScreenSizeInch() > 0 && laptop.GetScreen().GetSizeInch() > filter.GetMaxScreenSizeInch())
+		},
+	},
+	{
+		criterion: "screen_panel",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return filter.GetScreenPanel() == pb.Screen_UNKNOWN || laptop.GetScreen().GetPanel() == filter.GetScreenPanel()
+		},
+	},
+	{
+		criterion: "min_screen_resolution",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			resolution := laptop.GetScreen().GetResolution()
+			return !(resolution.GetWidth() < filter.GetMinScreenResolution().GetWidth() ||
+				resolution.GetHeight() < filter.GetMinScreenResolution().GetHeight())
+		},
+	},
+	{
+		criterion: "min_ssd_storage",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return !(totalSSDBits(laptop) < units.MemoryBits(filter.GetMinSsdStorage()))
+		},
+	},
+	{
+		criterion: "min_gpu_memory",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return !(maxGPUMemoryBits(laptop) < units.MemoryBits(filter.GetMinGpuMemory()))
+		},
+	},
+	{
+		criterion: "keyboard_layout",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return filter.GetKeyboardLayout() == pb.Keyboard_UNKNOWN || laptop.GetKeyboard().GetLayout() == filter.GetKeyboardLayout()
+		},
+	},
+	{
+		criterion: "require_backlit_keyboard",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			return !filter.GetRequireBacklitKeyboard() || laptop.GetKeyboard().GetBacklit()
+		},
+	},
+	{
+		criterion: "max_weight_kg",
+		passes: func(filter *pb.Filter, laptop *pb.Laptop) bool {
+			if filter.GetMaxWeightKg() <= 0 {
+				return true
+			}
+
+			weight, ok := units.WeightKg(laptop)
+			return ok && !(weight > filter.GetMaxWeightKg())
+		},
+	},
+}
+
+// isQualified reports whether the laptop satisfies every criterion of the filter,
+// criteria left to their zero value are ignored
+func isQualified(filter *pb.Filter, laptop *pb.Laptop) bool {
+	for _, check := range filterChecks {
+		if !check.passes(filter, laptop) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -2,14 +2,35 @@ package service
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 )
 
+var InvalidImageException = errors.New("invalid image")
+
 type ImageStore interface {
 	Save(laptopID string, imageType string, imageData bytes.Buffer) (string, error)
+
+	// DeleteByLaptopID removes every image saved for the laptop
+	DeleteByLaptopID(laptopID string) error
+
+	// DeleteAllExcept removes every image but the given ones,
+	// the data a kept image refers to is never removed
+	DeleteAllExcept(imageIDs []string) error
+
+	// FindByLaptopID returns the images saved for the laptop, ordered by id
+	FindByLaptopID(laptopID string) ([]*ImageInfo, error)
+
+	// Import records images whose data is already at their path, replacing the images with the same ids.
+	// Nothing is recorded unless every image has a UUID id and a path inside the store,
+	// InvalidImageException is returned otherwise
+	Import(images ...*ImageInfo) error
 }
 
 type DiskImageStore struct {
@@ -19,6 +40,7 @@ type DiskImageStore struct {
 }
 
 type ImageInfo struct {
+	ID       string
 	LaptopID string
 	Type     string
 	Path     string
@@ -53,6 +75,7 @@ func (store *DiskImageStore) Save(laptopID string, imageType string, imageData b
 	defer store.mutex.Unlock()
 
 	store.images[imageID.String()] = &ImageInfo{
+		ID:       imageID.String(),
 		LaptopID: laptopID,
 		Type:     imageType,
 		Path:     imagePath,
@@ -60,3 +83,123 @@ func (store *DiskImageStore) Save(laptopID string, imageType string, imageData b
 
 	return imageID.String(), nil
 }
+
+func (store *DiskImageStore) DeleteByLaptopID(laptopID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for imageID, image := range store.images {
+		if image.LaptopID != laptopID {
+			continue
+		}
+
+		err := store.delete(imageID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *DiskImageStore) DeleteAllExcept(imageIDs []string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	kept := make(map[string]bool)
+	for _, imageID := range imageIDs {
+		kept[imageID] = true
+	}
+
+	for imageID := range store.images {
+		if kept[imageID] {
+			continue
+		}
+
+		err := store.delete(imageID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// delete removes an image and its file, unless another image was imported with the same path.
+// It must be called with the mutex held.
+func (store *DiskImageStore) delete(imageID string) error {
+	image := store.images[imageID]
+	delete(store.images, imageID)
+
+	for _, other := range store.images {
+		if filepath.Clean(other.Path) == filepath.Clean(image.Path) {
+			return nil
+		}
+	}
+
+	err := os.Remove(image.Path)
+	if err != nil && !os.IsNotExist(err) {
+		store.images[imageID] = image
+		return fmt.Errorf("cannot delete image file: %v", err)
+	}
+
+	return nil
+}
+
+func (store *DiskImageStore) FindByLaptopID(laptopID string) ([]*ImageInfo, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	images := []*ImageInfo{}
+	for _, image := range store.images {
+		if image.LaptopID == laptopID {
+			other := *image
+			images = append(images, &other)
+		}
+	}
+
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].ID < images[j].ID
+	})
+
+	return images, nil
+}
+
+func (store *DiskImageStore) Import(images ...*ImageInfo) error {
+	for _, image := range images {
+		_, err := uuid.Parse(image.ID)
+		if err != nil {
+			return fmt.Errorf("%w: image ID is not a valid UUID: %v", InvalidImageException, err)
+		}
+
+		if !store.contains(image.Path) {
+			return fmt.Errorf("%w: image %s is outside of the image folder: %s", InvalidImageException, image.ID, image.Path)
+		}
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, image := range images {
+		other := *image
+		store.images[image.ID] = &other
+	}
+
+	return nil
+}
+
+// contains tells whether the path is inside the image folder, so that deleting an image never removes another file
+func (store *DiskImageStore) contains(path string) bool {
+	folder, err := filepath.Abs(store.imageFolder)
+	if err != nil {
+		return false
+	}
+
+	// Abs cleans the path, so .. elements can't climb out of the folder
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(path, folder+string(filepath.Separator))
+}
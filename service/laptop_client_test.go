@@ -2,25 +2,34 @@ package service
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/Adetunjii/go-grpc/pb"
 	"github.com/Adetunjii/go-grpc/sample"
 	"github.com/Adetunjii/go-grpc/serializer"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"io"
+	"math"
 	"net"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestClientCreateLaptop(t *testing.T) {
 	t.Parallel()
 
 	laptopStore := NewInMemoryLaptopStore()
-	laptopServer, serverAddress := startTestLaptopServer(t, laptopStore, nil)
+	laptopServer, serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
 	laptopClient := newTestLaptopClient(t, serverAddress)
 
 	laptop := sample.NewLaptop()
@@ -39,6 +48,10 @@ func TestClientCreateLaptop(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, other)
 
+	// the store stamps the first version on every new laptop
+	require.EqualValues(t, 1, other.Version)
+	laptop.Version = other.Version
+
 	laptop1, err := serializer.ProtobufToJSON(laptop)
 	require.NoError(t, err)
 
@@ -48,6 +61,58 @@ func TestClientCreateLaptop(t *testing.T) {
 	require.Equal(t, laptop1, laptop2)
 }
 
+func TestClientCreateLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	existing := sample.NewLaptop()
+	err := laptopStore.Save(existing)
+	require.NoError(t, err)
+
+	_, serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	laptopNoID := sample.NewLaptop()
+	laptopNoID.Id = ""
+
+	laptopInvalidID := sample.NewLaptop()
+	laptopInvalidID.Id = "invalid-uuid"
+
+	laptop := sample.NewLaptop()
+
+	laptops := []*pb.Laptop{laptop, existing, laptopInvalidID, laptopNoID, nil}
+	expectedCodes := []codes.Code{codes.OK, codes.AlreadyExists, codes.InvalidArgument, codes.OK, codes.InvalidArgument}
+
+	stream, err := laptopClient.CreateLaptops(context.Background())
+	require.NoError(t, err)
+
+	for _, laptop := range laptops {
+		err := stream.Send(&pb.CreateLaptopsRequest{Laptop: laptop})
+		require.NoError(t, err)
+	}
+
+	res, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	require.Len(t, res.GetResults(), len(laptops))
+	require.EqualValues(t, 2, res.GetCreatedCount())
+
+	for i, result := range res.GetResults() {
+		require.EqualValues(t, i, result.GetIndex())
+		require.Equal(t, expectedCodes[i], codes.Code(result.GetCode()))
+
+		if expectedCodes[i] != codes.OK {
+			require.Empty(t, result.GetId())
+			require.NotEmpty(t, result.GetMessage())
+			continue
+		}
+
+		other, err := laptopStore.FindById(result.GetId())
+		require.NoError(t, err)
+		require.NotNil(t, other)
+	}
+	require.Equal(t, laptop.GetId(), res.GetResults()[0].GetId())
+}
+
 func newTestLaptopClient(t *testing.T, address string) pb.LaptopServiceClient {
 	connection, err := grpc.Dial(address, grpc.WithInsecure())
 	require.NoError(t, err)
@@ -66,7 +131,7 @@ func TestClientUploadImage(t *testing.T) {
 	err := laptopStore.Save(laptop)
 	require.NoError(t, err)
 
-	_, serverAddress := startTestLaptopServer(t, laptopStore, imageStore)
+	_, serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil)
 	laptopClient := newTestLaptopClient(t, serverAddress)
 
 	imagePath := fmt.Sprintf("%s/k-mean-algorithm.jpg", testimageFolder)
@@ -123,8 +188,8 @@ func TestClientUploadImage(t *testing.T) {
 	require.NoError(t, os.Remove(savedImagePath))
 }
 
-func startTestLaptopServer(t *testing.T, laptopStore LaptopStore, imageStore ImageStore) (*LaptopServer, string) {
-	laptopServer := NewLaptopServer(laptopStore, imageStore)
+func startTestLaptopServer(t *testing.T, laptopStore LaptopStore, imageStore ImageStore, ratingStore RatingStore) (*LaptopServer, string) {
+	laptopServer := NewLaptopServer(laptopStore, imageStore, ratingStore, nil)
 
 	grpcServer := grpc.NewServer()
 	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
@@ -183,7 +248,7 @@ func TestClientSearchLaptop(t *testing.T) {
 		require.NoError(t, err)
 	}
 
-	_, serverAddress := startTestLaptopServer(t, laptopStore, nil)
+	_, serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
 	laptopClient := newTestLaptopClient(t, serverAddress)
 
 	req := &pb.SearchLaptopRequest{Filter: filter}
@@ -206,3 +271,923 @@ func TestClientSearchLaptop(t *testing.T) {
 
 	require.Equal(t, len(expectedIDs), found)
 }
+
+func TestClientRateLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	ratingStore := NewInMemoryRatingStore()
+
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	_, serverAddress := startTestLaptopServer(t, laptopStore, nil, ratingStore)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	stream, err := laptopClient.RateLaptop(context.Background())
+	require.NoError(t, err)
+
+	scores := []float64{8, 7.5, 10}
+	averages := []float64{8, 7.75, 8.5}
+
+	n := len(scores)
+	for i := 0; i < n; i++ {
+		req := &pb.RateLaptopRequest{
+			LaptopId: laptop.GetId(),
+			Score:    scores[i],
+		}
+
+		err := stream.Send(req)
+		require.NoError(t, err)
+	}
+
+	err = stream.CloseSend()
+	require.NoError(t, err)
+
+	for idx := 0; ; idx++ {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			require.Equal(t, n, idx)
+			return
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, laptop.GetId(), res.GetLaptopId())
+		require.Equal(t, uint32(idx+1), res.GetRatedCount())
+		require.Equal(t, averages[idx], res.GetAverageScore())
+	}
+}
+
+func TestClientRateLaptopUnknownID(t *testing.T) {
+	t.Parallel()
+
+	_, serverAddress := startTestLaptopServer(t, NewInMemoryLaptopStore(), nil, NewInMemoryRatingStore())
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	stream, err := laptopClient.RateLaptop(context.Background())
+	require.NoError(t, err)
+
+	err = stream.Send(&pb.RateLaptopRequest{LaptopId: sample.NewLaptop().GetId(), Score: 5})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestClientRateLaptopInvalidScore(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	ratingStore := NewInMemoryRatingStore()
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	_, serverAddress := startTestLaptopServer(t, laptopStore, nil, ratingStore)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	testCases := []struct {
+		name  string
+		score float64
+	}{
+		{"nan", math.NaN()},
+		{"positive_infinity", math.Inf(1)},
+		{"negative_infinity", math.Inf(-1)},
+		{"below_range", 0.5},
+		{"above_range", 10.5},
+		{"huge_negative", -1e300},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream, err := laptopClient.RateLaptop(context.Background())
+			require.NoError(t, err)
+
+			err = stream.Send(&pb.RateLaptopRequest{LaptopId: laptop.GetId(), Score: tc.score})
+			require.NoError(t, err)
+
+			_, err = stream.Recv()
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+
+	t.Cleanup(func() {
+		// the rejected scores were not added
+		rating, err := ratingStore.Add(laptop.GetId(), 5)
+		require.NoError(t, err)
+		require.Equal(t, &Rating{Count: 1, Sum: 5}, rating)
+	})
+}
+
+func TestClientWatchLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	_, serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	filter := &pb.Filter{MaxPriceUsd: 2000}
+	stream, err := laptopClient.WatchLaptops(ctx, &pb.WatchRequest{Filter: filter})
+	require.NoError(t, err)
+
+	// the watcher subscribes asynchronously on the server
+	require.Eventually(t, func() bool {
+		laptopStore.hub.mutex.Lock()
+		defer laptopStore.hub.mutex.Unlock()
+		return len(laptopStore.hub.watchers) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 2500
+	require.NoError(t, laptopStore.Save(expensive))
+
+	laptop := sample.NewLaptop()
+	laptop.PriceUsd = 1500
+	require.NoError(t, laptopStore.Save(laptop))
+
+	laptop.PriceUsd = 1800
+	_, err = laptopStore.Update(laptop, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 0)
+	require.NoError(t, err)
+	require.NoError(t, laptopStore.Delete(laptop.Id, 0))
+
+	expectedTypes := []pb.LaptopEvent_Type{pb.LaptopEvent_CREATED, pb.LaptopEvent_UPDATED, pb.LaptopEvent_DELETED}
+	for i, expectedType := range expectedTypes {
+		event, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, expectedType, event.GetType())
+		require.Equal(t, laptop.Id, event.GetLaptop().GetId())
+		require.EqualValues(t, i+1, event.GetLaptop().GetVersion())
+	}
+
+	// a laptop leaving the filter is sent once, then ignored
+	leaving := sample.NewLaptop()
+	leaving.PriceUsd = 1000
+	require.NoError(t, laptopStore.Save(leaving))
+
+	for _, price := range []float64{3000, 3500} {
+		leaving.PriceUsd = price
+		_, err = laptopStore.Update(leaving, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 0)
+		require.NoError(t, err)
+	}
+
+	last := sample.NewLaptop()
+	last.PriceUsd = 500
+	require.NoError(t, laptopStore.Save(last))
+
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, pb.LaptopEvent_CREATED, event.GetType())
+	require.Equal(t, leaving.Id, event.GetLaptop().GetId())
+	require.Nil(t, event.GetPreviousLaptop())
+
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, pb.LaptopEvent_UPDATED, event.GetType())
+	require.Equal(t, float64(3000), event.GetLaptop().GetPriceUsd())
+	require.Equal(t, float64(1000), event.GetPreviousLaptop().GetPriceUsd())
+
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, last.Id, event.GetLaptop().GetId())
+}
+
+func TestClientListLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	expectedIDs := make(map[string]bool)
+
+	for i := 0; i < 25; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, laptopStore.Save(laptop))
+		expectedIDs[laptop.Id] = true
+	}
+
+	deleted := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(deleted))
+	require.NoError(t, laptopStore.Delete(deleted.Id, 0))
+
+	_, serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	seen := make(map[string]bool)
+	lastID := ""
+	pageToken := ""
+	pages := 0
+
+	for {
+		res, err := laptopClient.ListLaptops(context.Background(), &pb.ListLaptopsRequest{
+			PageSize:  10,
+			PageToken: pageToken,
+		})
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(res.GetLaptops()), 10)
+		pages++
+
+		for _, laptop := range res.GetLaptops() {
+			require.Greater(t, laptop.GetId(), lastID)
+			require.False(t, seen[laptop.GetId()])
+			seen[laptop.GetId()] = true
+			lastID = laptop.GetId()
+		}
+
+		// laptops inserted between pages never shift the remaining pages
+		require.NoError(t, laptopStore.Save(sample.NewLaptop()))
+
+		pageToken = res.GetNextPageToken()
+		if pageToken == "" {
+			break
+		}
+	}
+
+	require.GreaterOrEqual(t, pages, 3)
+	require.NotContains(t, seen, deleted.Id)
+	for id := range expectedIDs {
+		require.Contains(t, seen, id)
+	}
+
+	_, err := laptopClient.ListLaptops(context.Background(), &pb.ListLaptopsRequest{PageToken: "not a token"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestClientSearchLaptopSorted(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	prices := []float64{1800, 1200, 2500, 1500, 900, 3000, 2100}
+
+	for _, price := range prices {
+		laptop := sample.NewLaptop()
+		laptop.PriceUsd = price
+		require.NoError(t, laptopStore.Save(laptop))
+	}
+
+	_, serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	testCases := []struct {
+		name     string
+		req      *pb.SearchLaptopRequest
+		expected []float64
+	}{
+		{
+			name: "cheapest_three",
+			req: &pb.SearchLaptopRequest{
+				SortKey:    pb.SearchLaptopRequest_PRICE,
+				MaxResults: 3,
+			},
+			expected: []float64{900, 1200, 1500},
+		},
+		{
+			name: "most_expensive_under_budget",
+			req: &pb.SearchLaptopRequest{
+				Filter:        &pb.Filter{MaxPriceUsd: 2500},
+				SortKey:       pb.SearchLaptopRequest_PRICE,
+				SortDirection: pb.SearchLaptopRequest_DESCENDING,
+				MaxResults:    2,
+			},
+			expected: []float64{2500, 2100},
+		},
+		{
+			name: "sorted_without_limit",
+			req: &pb.SearchLaptopRequest{
+				SortKey: pb.SearchLaptopRequest_PRICE,
+			},
+			expected: []float64{900, 1200, 1500, 1800, 2100, 2500, 3000},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream, err := laptopClient.SearchLaptop(context.Background(), tc.req)
+			require.NoError(t, err)
+
+			found := []float64{}
+			for {
+				res, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+
+				require.NoError(t, err)
+				found = append(found, res.GetLaptop().GetPriceUsd())
+			}
+
+			require.Equal(t, tc.expected, found)
+		})
+	}
+
+	stream, err := laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{MaxResults: 4})
+	require.NoError(t, err)
+
+	found := 0
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+		found++
+	}
+	require.Equal(t, 4, found)
+}
+
+func TestClientSearchLaptopQuery(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	laptops := []struct {
+		id    string
+		brand string
+		name  string
+		cpu   string
+		gpu   string
+		price float64
+	}{
+		{"00000000-0000-0000-0000-000000000001", "Asus", "ZenBook", "Ryzen 7 PRO 2700U", "RTX 2070", 2000},
+		{"00000000-0000-0000-0000-000000000002", "Lenovo", "ThinkPad", "Core i7", "RTX 2070", 1500},
+		{"00000000-0000-0000-0000-000000000003", "Lenovo", "Legion", "Ryzen 7 PRO 2700U", "RTX 2060", 1800},
+		{"00000000-0000-0000-0000-000000000004", "Asus", "Ryzen Special", "Ryzen 5 PRO 3500U", "RX 580", 1200},
+	}
+
+	for _, l := range laptops {
+		laptop := sample.NewLaptop()
+		laptop.Id = l.id
+		laptop.Brand = l.brand
+		laptop.Name = l.name
+		laptop.Cpu.Name = l.cpu
+		laptop.Gpus[0].Name = l.gpu
+		laptop.PriceUsd = l.price
+		require.NoError(t, laptopStore.Save(laptop))
+	}
+
+	_, serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	testCases := []struct {
+		name     string
+		req      *pb.SearchLaptopRequest
+		expected []string
+	}{
+		{
+			name:     "every_word_must_match",
+			req:      &pb.SearchLaptopRequest{Query: "ryzen 7 rtx 2070"},
+			expected: []string{"ZenBook"},
+		},
+		{
+			name:     "name_ranks_first",
+			req:      &pb.SearchLaptopRequest{Query: "RYZEN"},
+			expected: []string{"Ryzen Special", "ZenBook", "Legion"},
+		},
+		{
+			name:     "prefix",
+			req:      &pb.SearchLaptopRequest{Query: "leno"},
+			expected: []string{"ThinkPad", "Legion"},
+		},
+		{
+			name: "with_filter",
+			req: &pb.SearchLaptopRequest{
+				Query:  "lenovo",
+				Filter: &pb.Filter{MaxPriceUsd: 1600},
+			},
+			expected: []string{"ThinkPad"},
+		},
+		{
+			name: "sort_key_overrides_relevance",
+			req: &pb.SearchLaptopRequest{
+				Query:         "ryzen",
+				SortKey:       pb.SearchLaptopRequest_PRICE,
+				SortDirection: pb.SearchLaptopRequest_DESCENDING,
+				MaxResults:    2,
+			},
+			expected: []string{"ZenBook", "Legion"},
+		},
+		{
+			name:     "no_match",
+			req:      &pb.SearchLaptopRequest{Query: "macbook"},
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream, err := laptopClient.SearchLaptop(context.Background(), tc.req)
+			require.NoError(t, err)
+
+			found := []string{}
+			for {
+				res, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+
+				require.NoError(t, err)
+				found = append(found, res.GetLaptop().GetName())
+			}
+
+			require.Equal(t, tc.expected, found)
+		})
+	}
+}
+
+func TestClientSearchLaptopFilterExpression(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	prices := []float64{900, 1500, 2100, 2700}
+
+	for _, price := range prices {
+		laptop := sample.NewLaptop()
+		laptop.PriceUsd = price
+		laptop.Ram = &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}
+		require.NoError(t, laptopStore.Save(laptop))
+	}
+
+	_, serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	stream, err := laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{
+		FilterExpression: `(price_usd < 1000 OR price_usd > 2500) AND ram >= 16GB AND brand IN ("Apple")`,
+		SortKey:          pb.SearchLaptopRequest_PRICE,
+	})
+	require.NoError(t, err)
+
+	found := []float64{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+		found = append(found, res.GetLaptop().GetPriceUsd())
+	}
+	require.Equal(t, []float64{900, 2700}, found)
+
+	testCases := []struct {
+		name    string
+		req     *pb.SearchLaptopRequest
+		message string
+	}{
+		{
+			name:    "syntax_error",
+			req:     &pb.SearchLaptopRequest{FilterExpression: `price_usd <= "cheap"`},
+			message: "column 14",
+		},
+		{
+			name: "filter_and_expression",
+			req: &pb.SearchLaptopRequest{
+				Filter:           &pb.Filter{MaxPriceUsd: 2000},
+				FilterExpression: `price_usd <= 2000`,
+			},
+			message: "cannot be used together",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream, err := laptopClient.SearchLaptop(context.Background(), tc.req)
+			require.NoError(t, err)
+
+			_, err = stream.Recv()
+			st, ok := status.FromError(err)
+			require.True(t, ok)
+			require.Equal(t, codes.InvalidArgument, st.Code())
+			require.Contains(t, st.Message(), tc.message)
+		})
+	}
+}
+
+func TestClientSearchLaptopExplain(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	prices := []float64{900, 1500, 2100, 2700}
+
+	for _, price := range prices {
+		laptop := sample.NewLaptop()
+		laptop.PriceUsd = price
+		require.NoError(t, laptopStore.Save(laptop))
+	}
+
+	_, serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	stream, err := laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{
+		Filter:  &pb.Filter{MaxPriceUsd: 2000},
+		Explain: true,
+	})
+	require.NoError(t, err)
+
+	found := 0
+	var explanation *pb.SearchExplanation
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+		require.Nil(t, explanation, "the explanation must be the last message")
+
+		if res.GetExplanation() != nil {
+			require.Nil(t, res.GetLaptop())
+			explanation = res.GetExplanation()
+			continue
+		}
+
+		found++
+	}
+
+	require.Equal(t, 2, found)
+	require.NotNil(t, explanation)
+	require.Equal(t, uint32(4), explanation.GetScannedCount())
+	require.Equal(t, uint32(2), explanation.GetMatchedCount())
+	require.Len(t, explanation.GetRejections(), 1)
+	require.Equal(t, "max_price_usd", explanation.GetRejections()[0].GetCriterion())
+	require.Equal(t, uint32(2), explanation.GetRejections()[0].GetRejectedCount())
+	require.Len(t, explanation.GetNearMisses(), 2)
+}
+
+func TestClientSavedSearches(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	laptopServer := NewLaptopServer(laptopStore, nil, nil, NewInMemorySavedSearchStore())
+
+	jwtManager := NewJWTManager("secret", time.Minute)
+	const laptopServicePath = "/LaptopService/"
+	interceptor := NewAuthInterceptor(jwtManager, map[string][]string{
+		laptopServicePath + "SaveSearch":         {"user"},
+		laptopServicePath + "ListSavedSearches":  {"user"},
+		laptopServicePath + "DeleteSavedSearch":  {"user"},
+		laptopServicePath + "StreamSearchAlerts": {"user"},
+	})
+
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(interceptor.Unary()),
+		grpc.StreamInterceptor(interceptor.Stream()))
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	laptopClient := newTestLaptopClient(t, listener.Addr().String())
+
+	loggedIn := func(ctx context.Context, username string) context.Context {
+		token, err := jwtManager.Generate(&User{Username: username, Role: "user"})
+		require.NoError(t, err)
+		return metadata.AppendToOutgoingContext(ctx, "authorization", token)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	alice := loggedIn(ctx, "alice")
+	bob := loggedIn(ctx, "bob")
+
+	_, err = laptopClient.SaveSearch(ctx, &pb.SaveSearchRequest{Name: "anonymous"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = laptopClient.SaveSearch(alice, &pb.SaveSearchRequest{Name: " "})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = laptopClient.SaveSearch(alice, &pb.SaveSearchRequest{Name: "no filter"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = laptopClient.SaveSearch(alice, &pb.SaveSearchRequest{Name: "empty filter", Filter: &pb.Filter{}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	cheap, err := laptopClient.SaveSearch(alice, &pb.SaveSearchRequest{Name: "cheap", Filter: &pb.Filter{MaxPriceUsd: 1500}})
+	require.NoError(t, err)
+	_, err = laptopClient.SaveSearch(bob, &pb.SaveSearchRequest{Name: "anything", Filter: &pb.Filter{MaxPriceUsd: 3000}})
+	require.NoError(t, err)
+
+	// searches are scoped to their owner
+	res, err := laptopClient.ListSavedSearches(alice, &pb.ListSavedSearchesRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.GetSavedSearches(), 1)
+	require.Equal(t, cheap.GetSavedSearch().GetId(), res.GetSavedSearches()[0].GetId())
+
+	_, err = laptopClient.DeleteSavedSearch(bob, &pb.DeleteSavedSearchRequest{Id: cheap.GetSavedSearch().GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err := laptopClient.StreamSearchAlerts(alice, &pb.StreamSearchAlertsRequest{})
+	require.NoError(t, err)
+
+	// the alert stream subscribes asynchronously on the server
+	require.Eventually(t, func() bool {
+		laptopStore.hub.mutex.Lock()
+		defer laptopStore.hub.mutex.Unlock()
+		return len(laptopStore.hub.watchers) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 2500
+	require.NoError(t, laptopStore.Save(expensive))
+
+	laptop := sample.NewLaptop()
+	laptop.PriceUsd = 1200
+	require.NoError(t, laptopStore.Save(laptop))
+
+	alert, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, laptop.Id, alert.GetLaptop().GetId())
+	require.Len(t, alert.GetSavedSearches(), 1)
+	require.Equal(t, "cheap", alert.GetSavedSearches()[0].GetName())
+
+	// a new search applies to the following changes right away
+	_, err = laptopClient.SaveSearch(alice, &pb.SaveSearchRequest{Name: "apple", Filter: &pb.Filter{Brands: []string{"apple"}}})
+	require.NoError(t, err)
+
+	require.NoError(t, laptopStore.Delete(laptop.Id, 0))
+	expensive.PriceUsd = 2600
+	_, err = laptopStore.Update(expensive, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 0)
+	require.NoError(t, err)
+
+	alert, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, expensive.Id, alert.GetLaptop().GetId())
+	require.Len(t, alert.GetSavedSearches(), 1)
+	require.Equal(t, "apple", alert.GetSavedSearches()[0].GetName())
+
+	_, err = laptopClient.DeleteSavedSearch(alice, &pb.DeleteSavedSearchRequest{Id: cheap.GetSavedSearch().GetId()})
+	require.NoError(t, err)
+
+	res, err = laptopClient.ListSavedSearches(alice, &pb.ListSavedSearchesRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.GetSavedSearches(), 1)
+	require.Equal(t, "apple", res.GetSavedSearches()[0].GetName())
+}
+
+func exportTestCatalog(t *testing.T, laptopClient pb.LaptopServiceClient) *pb.Catalog {
+	stream, err := laptopClient.ExportCatalog(context.Background(), &pb.ExportCatalogRequest{})
+	require.NoError(t, err)
+
+	catalog := &pb.Catalog{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return catalog
+		}
+
+		require.NoError(t, err)
+		catalog.Entries = append(catalog.Entries, res.GetEntry())
+	}
+}
+
+func importTestCatalog(t *testing.T, laptopClient pb.LaptopServiceClient, catalog *pb.Catalog, mode pb.ImportCatalogInfo_Mode) (*pb.ImportCatalogResponse, error) {
+	stream, err := laptopClient.ImportCatalog(context.Background())
+	require.NoError(t, err)
+
+	err = stream.Send(&pb.ImportCatalogRequest{
+		Data: &pb.ImportCatalogRequest_Info{Info: &pb.ImportCatalogInfo{Mode: mode}},
+	})
+	require.NoError(t, err)
+
+	for _, entry := range catalog.GetEntries() {
+		err := stream.Send(&pb.ImportCatalogRequest{
+			Data: &pb.ImportCatalogRequest_Entry{Entry: entry},
+		})
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	return stream.CloseAndRecv()
+}
+
+// failingSaveLaptopStore fails to save one laptop
+type failingSaveLaptopStore struct {
+	*InMemoryLaptopStore
+	failingID string
+}
+
+func (store *failingSaveLaptopStore) Save(laptop *pb.Laptop) error {
+	if laptop.GetId() == store.failingID {
+		return errors.New("disk failure")
+	}
+	return store.InMemoryLaptopStore.Save(laptop)
+}
+
+func catalogEntry(t *testing.T, catalog *pb.Catalog, laptopID string) *pb.CatalogEntry {
+	for _, entry := range catalog.GetEntries() {
+		if entry.GetLaptop().GetId() == laptopID {
+			return entry
+		}
+	}
+
+	require.Failf(t, "laptop is not in the catalog", "laptop %s", laptopID)
+	return nil
+}
+
+func TestClientExportImportCatalog(t *testing.T) {
+	t.Parallel()
+
+	// the image files are not part of the catalog, so the servers share their image folder
+	imageFolder := t.TempDir()
+
+	sourceStore := NewInMemoryLaptopStore()
+	sourceImages := NewDiskImageStore(imageFolder)
+
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop(), sample.NewLaptop()}
+	for _, laptop := range laptops {
+		require.NoError(t, sourceStore.Save(laptop))
+	}
+	require.NoError(t, sourceStore.Delete(laptops[2].Id, 0))
+	imageID, err := sourceImages.Save(laptops[0].Id, ".jpg", *bytes.NewBufferString("image"))
+	require.NoError(t, err)
+	exportedImages, err := sourceImages.FindByLaptopID(laptops[0].Id)
+	require.NoError(t, err)
+
+	_, sourceAddress := startTestLaptopServer(t, sourceStore, sourceImages, nil)
+	sourceClient := newTestLaptopClient(t, sourceAddress)
+
+	// the catalog goes through a binary file like with the client commands
+	catalogFile := filepath.Join(t.TempDir(), "catalog.bin")
+	require.NoError(t, serializer.WriteProtobufToBinaryFile(exportTestCatalog(t, sourceClient), catalogFile))
+
+	catalog := &pb.Catalog{}
+	require.NoError(t, serializer.ReadProtobufFromBinaryFile(catalogFile, catalog))
+
+	// laptops in the trash are not exported
+	require.Len(t, catalog.GetEntries(), 2)
+	for _, entry := range catalog.GetEntries() {
+		if entry.GetLaptop().GetId() == laptops[0].Id {
+			require.Len(t, entry.GetImages(), 1)
+			require.Equal(t, imageID, entry.GetImages()[0].GetId())
+		} else {
+			require.Equal(t, laptops[1].Id, entry.GetLaptop().GetId())
+			require.Empty(t, entry.GetImages())
+		}
+	}
+
+	// failingID is the id of a laptop the target fails to save, if any
+	newTarget := func(failingID string) (*InMemoryLaptopStore, *DiskImageStore, pb.LaptopServiceClient, *pb.Laptop) {
+		laptopStore := NewInMemoryLaptopStore()
+		imageStore := NewDiskImageStore(imageFolder)
+
+		// an unrelated laptop and an older version of an exported one, in the trash, both with an image.
+		// The target also knows the exported image, as when importing back into the exporting server
+		other := sample.NewLaptop()
+		require.NoError(t, laptopStore.Save(other))
+		_, err := imageStore.Save(other.Id, ".jpg", *bytes.NewBufferString("other image"))
+		require.NoError(t, err)
+
+		older := sample.NewLaptop()
+		older.Id = laptops[0].Id
+		older.PriceUsd = laptops[0].PriceUsd + 100
+		require.NoError(t, laptopStore.Save(older))
+		require.NoError(t, laptopStore.Delete(older.Id, 0))
+		_, err = imageStore.Save(older.Id, ".jpg", *bytes.NewBufferString("older image"))
+		require.NoError(t, err)
+		require.NoError(t, imageStore.Import(exportedImages...))
+
+		var serverStore LaptopStore = laptopStore
+		if failingID != "" {
+			serverStore = &failingSaveLaptopStore{InMemoryLaptopStore: laptopStore, failingID: failingID}
+		}
+
+		_, address := startTestLaptopServer(t, serverStore, imageStore, nil)
+		return laptopStore, imageStore, newTestLaptopClient(t, address), other
+	}
+
+	imageExists := func(path string) bool {
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return false
+		}
+
+		require.NoError(t, err)
+		return true
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		t.Parallel()
+
+		laptopStore, imageStore, laptopClient, other := newTarget("")
+		res, err := importTestCatalog(t, laptopClient, catalog, pb.ImportCatalogInfo_MERGE)
+		require.NoError(t, err)
+		require.EqualValues(t, 2, res.GetImportedCount())
+		require.Zero(t, res.GetRemovedCount())
+
+		require.ElementsMatch(t, []string{other.Id, laptops[0].Id, laptops[1].Id}, storeSearchIds(t, laptopStore, nil))
+
+		merged, err := laptopStore.FindById(laptops[0].Id)
+		require.NoError(t, err)
+		require.Equal(t, laptops[0].PriceUsd, merged.GetPriceUsd())
+
+		images, err := imageStore.FindByLaptopID(laptops[0].Id)
+		require.NoError(t, err)
+		require.Len(t, images, 2, "the images of the older laptop are kept")
+		require.Contains(t, images, exportedImages[0])
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		t.Parallel()
+
+		laptopStore, imageStore, laptopClient, other := newTarget("")
+		otherImages, err := imageStore.FindByLaptopID(other.Id)
+		require.NoError(t, err)
+		olderImages, err := imageStore.FindByLaptopID(laptops[0].Id)
+		require.NoError(t, err)
+
+		res, err := importTestCatalog(t, laptopClient, catalog, pb.ImportCatalogInfo_REPLACE)
+		require.NoError(t, err)
+		require.EqualValues(t, 2, res.GetImportedCount())
+		// the older version in the trash is replaced by the imported one rather than removed
+		require.EqualValues(t, 1, res.GetRemovedCount())
+
+		require.ElementsMatch(t, []string{laptops[0].Id, laptops[1].Id}, storeSearchIds(t, laptopStore, nil))
+
+		replaced, err := laptopStore.FindById(laptops[0].Id)
+		require.NoError(t, err)
+		require.Equal(t, laptops[0].PriceUsd, replaced.GetPriceUsd())
+		require.Nil(t, replaced.GetDeletedAt())
+
+		images, err := imageStore.FindByLaptopID(laptops[0].Id)
+		require.NoError(t, err)
+		require.Equal(t, exportedImages, images)
+		require.True(t, imageExists(exportedImages[0].Path), "the file of an imported image must be kept")
+
+		// the images which are not in the catalog are removed with their file
+		otherImages = append(otherImages, olderImages...)
+		for _, image := range otherImages {
+			if image.ID != exportedImages[0].ID {
+				require.False(t, imageExists(image.Path))
+			}
+		}
+		images, err = imageStore.FindByLaptopID(other.Id)
+		require.NoError(t, err)
+		require.Empty(t, images)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		t.Parallel()
+
+		laptopStore, imageStore, laptopClient, other := newTarget(laptops[1].Id)
+		_, err := importTestCatalog(t, laptopClient, catalog, pb.ImportCatalogInfo_REPLACE)
+		require.Equal(t, codes.Internal, status.Code(err))
+		require.Contains(t, status.Convert(err).Message(), "of 2 laptops imported")
+
+		// the other laptops are only removed once the whole catalog is imported
+		_, err = laptopStore.FindById(other.Id)
+		require.NoError(t, err)
+		images, err := imageStore.FindByLaptopID(other.Id)
+		require.NoError(t, err)
+		require.Len(t, images, 1)
+		require.True(t, imageExists(images[0].Path))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		laptopStore, imageStore, laptopClient, other := newTarget("")
+
+		_, err := importTestCatalog(t, laptopClient, catalog, pb.ImportCatalogInfo_UNKNOWN)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		entries := catalog.GetEntries()
+		duplicated := &pb.Catalog{Entries: []*pb.CatalogEntry{entries[0], entries[1], entries[0]}}
+		_, err = importTestCatalog(t, laptopClient, duplicated, pb.ImportCatalogInfo_REPLACE)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		badImage := proto.Clone(catalogEntry(t, catalog, laptops[0].Id)).(*pb.CatalogEntry)
+		badImage.Images[0].Id = "image-1"
+		_, err = importTestCatalog(t, laptopClient, &pb.Catalog{Entries: []*pb.CatalogEntry{badImage}}, pb.ImportCatalogInfo_REPLACE)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		for _, path := range []string{"/etc/passwd", filepath.Join(imageFolder, "..", "outside.jpg")} {
+			badImage := proto.Clone(catalogEntry(t, catalog, laptops[0].Id)).(*pb.CatalogEntry)
+			badImage.Images[0].Path = path
+			badCatalog := &pb.Catalog{Entries: []*pb.CatalogEntry{catalogEntry(t, catalog, laptops[1].Id), badImage}}
+			_, err = importTestCatalog(t, laptopClient, badCatalog, pb.ImportCatalogInfo_REPLACE)
+			require.Equal(t, codes.InvalidArgument, status.Code(err), path)
+		}
+
+		// nothing changed since the catalog is checked before importing
+		require.Equal(t, []string{other.Id}, storeSearchIds(t, laptopStore, nil))
+		images, err := imageStore.FindByLaptopID(laptops[0].Id)
+		require.NoError(t, err)
+		require.Contains(t, images, exportedImages[0])
+	})
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"github.com/Adetunjii/go-grpc/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"sync"
+)
+
+// number of events a watcher can lag behind before it is disconnected
+const watchBufferSize = 64
+
+// laptopEventHub fans laptop change events out to every watcher.
+// Publishing never blocks: a watcher whose buffer is full is dropped and its channel closed.
+type laptopEventHub struct {
+	mutex    sync.Mutex
+	watchers map[chan *pb.LaptopEvent]bool
+}
+
+func newLaptopEventHub() *laptopEventHub {
+	return &laptopEventHub{
+		watchers: make(map[chan *pb.LaptopEvent]bool),
+	}
+}
+
+// subscribe returns a channel receiving every event published from now on,
+// it is closed when the context is done or the watcher falls behind
+func (hub *laptopEventHub) subscribe(ctx context.Context) <-chan *pb.LaptopEvent {
+	events := make(chan *pb.LaptopEvent, watchBufferSize)
+
+	hub.mutex.Lock()
+	hub.watchers[events] = true
+	hub.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		hub.unsubscribe(events)
+	}()
+
+	return events
+}
+
+func (hub *laptopEventHub) unsubscribe(events chan *pb.LaptopEvent) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	hub.remove(events)
+}
+
+// remove must be called with the hub mutex held
+func (hub *laptopEventHub) remove(events chan *pb.LaptopEvent) {
+	if hub.watchers[events] {
+		delete(hub.watchers, events)
+		close(events)
+	}
+}
+
+// publish sends a copy of the laptop to every watcher along with a copy of its previous state,
+// which is nil for a created laptop
+func (hub *laptopEventHub) publish(eventType pb.LaptopEvent_Type, previous *pb.Laptop, laptop *pb.Laptop) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	if len(hub.watchers) == 0 {
+		return
+	}
+
+	other, err := deepCopy(laptop)
+	if err != nil {
+		return
+	}
+
+	event := &pb.LaptopEvent{
+		Type:       eventType,
+		Laptop:     other,
+		OccurredAt: timestamppb.Now(),
+	}
+
+	if previous != nil {
+		event.PreviousLaptop, err = deepCopy(previous)
+		if err != nil {
+			return
+		}
+	}
+
+	for events := range hub.watchers {
+		select {
+		case events <- event:
+		default:
+			// slow watcher, drop it so that writers are never blocked
+			hub.remove(events)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"fmt"
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/units"
+	"sort"
+	"strconv"
+)
+
+// facetValue is the bucket a laptop falls into,
+// rank orders the buckets of ranged facets
+type facetValue struct {
+	label string
+	rank  float64
+}
+
+// facetField describes how laptops are bucketed for one facet
+type facetField struct {
+	name   string
+	ranged bool // buckets follow their rank instead of their count
+	values func(laptop *pb.Laptop) []facetValue
+}
+
+// upper bounds of the price ranges in USD, the last range has no upper bound
+var priceRanges = []float64{500, 1000, 1500, 2000, 2500, 3000}
+
+var facetFields = []facetField{
+	{
+		name: "brand",
+		values: func(laptop *pb.Laptop) []facetValue {
+			return []facetValue{{label: laptop.GetBrand()}}
+		},
+	},
+	{
+		name: "cpu.brand",
+		values: func(laptop *pb.Laptop) []facetValue {
+			return []facetValue{{label: laptop.GetCpu().GetBrand()}}
+		},
+	},
+	{
+		name:   "ram",
+		ranged: true,
+		values: func(laptop *pb.Laptop) []facetValue {
+			bits := units.MemoryBits(laptop.GetRam())
+			return []facetValue{{label: memoryLabel(bits), rank: float64(bits)}}
+		},
+	},
+	{
+		name: "storages.driver",
+		values: func(laptop *pb.Laptop) []facetValue {
+			// a laptop with two SSDs is counted once
+			seen := make(map[pb.Storage_Driver]bool)
+			values := []facetValue{}
+			for _, storage := range laptop.GetStorages() {
+				if !seen[storage.GetDriver()] {
+					seen[storage.GetDriver()] = true
+					values = append(values, facetValue{label: storage.GetDriver().String()})
+				}
+			}
+			return values
+		},
+	},
+	{
+		name: "screen.panel",
+		values: func(laptop *pb.Laptop) []facetValue {
+			return []facetValue{{label: laptop.GetScreen().GetPanel().String()}}
+		},
+	},
+	{
+		name: "keyboard.layout",
+		values: func(laptop *pb.Laptop) []facetValue {
+			return []facetValue{{label: laptop.GetKeyboard().GetLayout().String()}}
+		},
+	},
+	{
+		name:   "release_year",
+		ranged: true,
+		values: func(laptop *pb.Laptop) []facetValue {
+			year := laptop.GetReleaseYear()
+			return []facetValue{{label: strconv.FormatUint(uint64(year), 10), rank: float64(year)}}
+		},
+	},
+	{
+		name:   "price_usd",
+		ranged: true,
+		values: func(laptop *pb.Laptop) []facetValue {
+			price := laptop.GetPriceUsd()
+			lower := 0.0
+			for _, upper := range priceRanges {
+				if price < upper {
+					return []facetValue{{label: fmt.Sprintf("%g-%g", lower, upper), rank: lower}}
+				}
+				lower = upper
+			}
+			return []facetValue{{label: fmt.Sprintf("%g+", lower), rank: lower}}
+		},
+	},
+}
+
+var memoryUnits = []pb.Memory_Unit{
+	pb.Memory_TERABYTE,
+	pb.Memory_GIGABYTE,
+	pb.Memory_MEGABYTE,
+	pb.Memory_KILOBYTE,
+	pb.Memory_BYTE,
+}
+
+var memoryUnitSymbols = map[pb.Memory_Unit]string{
+	pb.Memory_TERABYTE: "TB",
+	pb.Memory_GIGABYTE: "GB",
+	pb.Memory_MEGABYTE: "MB",
+	pb.Memory_KILOBYTE: "KB",
+	pb.Memory_BYTE:     "B",
+}
+
+// memoryLabel writes the size in the largest unit dividing it,
+// so that 16 GB and 16384 MB share the same bucket
+func memoryLabel(bits uint64) string {
+	for _, unit := range memoryUnits {
+		size := units.ToBit(1, unit)
+		if bits > 0 && bits%size == 0 {
+			return fmt.Sprintf("%d %s", bits/size, memoryUnitSymbols[unit])
+		}
+	}
+
+	return fmt.Sprintf("%d bit", bits)
+}
+
+type facetBucket struct {
+	value facetValue
+	count uint32
+}
+
+// laptopFacets counts the laptops falling in each bucket of every facet
+type laptopFacets struct {
+	total   uint32
+	buckets []map[string]*facetBucket // one per facetFields entry, by label
+}
+
+func newLaptopFacets() *laptopFacets {
+	buckets := make([]map[string]*facetBucket, len(facetFields))
+	for i := range buckets {
+		buckets[i] = make(map[string]*facetBucket)
+	}
+
+	return &laptopFacets{buckets: buckets}
+}
+
+func (facets *laptopFacets) add(laptop *pb.Laptop) {
+	facets.total++
+
+	for i, field := range facetFields {
+		for _, value := range field.values(laptop) {
+			bucket := facets.buckets[i][value.label]
+			if bucket == nil {
+				bucket = &facetBucket{value: value}
+				facets.buckets[i][value.label] = bucket
+			}
+			bucket.count++
+		}
+	}
+}
+
+func (facets *laptopFacets) toProto() []*pb.Facet {
+	result := make([]*pb.Facet, len(facetFields))
+
+	for i, field := range facetFields {
+		buckets := make([]*facetBucket, 0, len(facets.buckets[i]))
+		for _, bucket := range facets.buckets[i] {
+			buckets = append(buckets, bucket)
+		}
+
+		sort.Slice(buckets, func(a, b int) bool {
+			if field.ranged && buckets[a].value.rank != buckets[b].value.rank {
+				return buckets[a].value.rank < buckets[b].value.rank
+			}
+			if !field.ranged && buckets[a].count != buckets[b].count {
+				return buckets[a].count > buckets[b].count
+			}
+			return buckets[a].value.label < buckets[b].value.label
+		})
+
+		facet := &pb.Facet{Name: field.name}
+		for _, bucket := range buckets {
+			facet.Buckets = append(facet.Buckets, &pb.FacetBucket{
+				Value: bucket.value.label,
+				Count: bucket.count,
+			})
+		}
+
+		result[i] = facet
+	}
+
+	return result
+}
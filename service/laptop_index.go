@@ -0,0 +1,231 @@
+package service
+
+import (
+	"errors"
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/units"
+	"math"
+	"sort"
+)
+
+// indexEntry is ordered by key first and laptop id second
+type indexEntry struct {
+	key float64
+	id  string
+}
+
+func (entry indexEntry) less(other indexEntry) bool {
+	if entry.key != other.key {
+		return entry.key < other.key
+	}
+	return entry.id < other.id
+}
+
+// maximum number of entries in an index block before it is split in two
+const indexBlockSize = 512
+
+// laptopIndex is an ordered set of entries stored in sorted blocks,
+// so that inserts and removals only shift a single small block
+type laptopIndex struct {
+	blocks [][]indexEntry
+}
+
+func newLaptopIndex() *laptopIndex {
+	return &laptopIndex{}
+}
+
+// position of the first entry that is not less than the given entry
+func (index *laptopIndex) seek(entry indexEntry) (int, int) {
+	i := sort.Search(len(index.blocks), func(i int) bool {
+		block := index.blocks[i]
+		return !block[len(block)-1].less(entry)
+	})
+
+	if i == len(index.blocks) {
+		return i, 0
+	}
+
+	block := index.blocks[i]
+	j := sort.Search(len(block), func(j int) bool {
+		return !block[j].less(entry)
+	})
+
+	return i, j
+}
+
+func (index *laptopIndex) insert(entry indexEntry) {
+	if len(index.blocks) == 0 {
+		index.blocks = [][]indexEntry{{entry}}
+		return
+	}
+
+	i, j := index.seek(entry)
+	if i == len(index.blocks) {
+		// greater than every entry, append to the last block
+		i = len(index.blocks) - 1
+		j = len(index.blocks[i])
+	}
+
+	block := index.blocks[i]
+	if j < len(block) && block[j] == entry {
+		return
+	}
+
+	block = append(block, indexEntry{})
+	copy(block[j+1:], block[j:])
+	block[j] = entry
+	index.blocks[i] = block
+
+	if len(block) > indexBlockSize {
+		half := len(block) / 2
+		left := append([]indexEntry{}, block[:half]...)
+		right := append([]indexEntry{}, block[half:]...)
+
+		index.blocks = append(index.blocks, nil)
+		copy(index.blocks[i+2:], index.blocks[i+1:])
+		index.blocks[i] = left
+		index.blocks[i+1] = right
+	}
+}
+
+func (index *laptopIndex) remove(entry indexEntry) {
+	i, j := index.seek(entry)
+	if i == len(index.blocks) || index.blocks[i][j] != entry {
+		return
+	}
+
+	block := index.blocks[i]
+	block = append(block[:j], block[j+1:]...)
+
+	if len(block) == 0 {
+		index.blocks = append(index.blocks[:i], index.blocks[i+1:]...)
+		return
+	}
+
+	index.blocks[i] = block
+}
+
+// count returns the number of entries in [from, to)
+func (index *laptopIndex) count(from indexEntry, to indexEntry) int {
+	fromBlock, fromOffset := index.seek(from)
+	toBlock, toOffset := index.seek(to)
+
+	if fromBlock == toBlock {
+		return toOffset - fromOffset
+	}
+
+	count := len(index.blocks[fromBlock]) - fromOffset
+	for i := fromBlock + 1; i < toBlock; i++ {
+		count += len(index.blocks[i])
+	}
+
+	if toBlock < len(index.blocks) {
+		count += toOffset
+	}
+
+	return count
+}
+
+var errStopAscend = errors.New("stop ascending the index")
+
+// ascend calls visit for every entry from the given one in order,
+// until visit returns an error which is passed back to the caller
+func (index *laptopIndex) ascend(from indexEntry, visit func(entry indexEntry) error) error {
+	i, j := index.seek(from)
+
+	for ; i < len(index.blocks); i++ {
+		block := index.blocks[i]
+		for ; j < len(block); j++ {
+			err := visit(block[j])
+			if err != nil {
+				return err
+			}
+		}
+		j = 0
+	}
+
+	return nil
+}
+
+// ascendRange calls visit with the id of every entry whose key is in [min, max]
+func (index *laptopIndex) ascendRange(min float64, max float64, visit func(id string) error) error {
+	from, to := rangeEntries(min, max)
+
+	err := index.ascend(from, func(entry indexEntry) error {
+		if !entry.less(to) {
+			return errStopAscend
+		}
+		return visit(entry.id)
+	})
+
+	if errors.Is(err, errStopAscend) {
+		return nil
+	}
+
+	return err
+}
+
+// rangeEntries converts the inclusive key range [min, max] to the entries bounding it
+func rangeEntries(min float64, max float64) (indexEntry, indexEntry) {
+	return indexEntry{key: min}, indexEntry{key: math.Nextafter(max, math.Inf(1))}
+}
+
+// indexedField describes a laptop field kept in a secondary index
+type indexedField struct {
+	name string
+	key  func(laptop *pb.Laptop) float64
+
+	// bounds returns the key range allowed by the filter, ok is false if the filter doesn't constrain the field
+	bounds func(filter *pb.Filter) (min float64, max float64, ok bool)
+}
+
+var indexedFields = []indexedField{
+	{
+		name: "price_usd",
+		key: func(laptop *pb.Laptop) float64 {
+			return laptop.GetPriceUsd()
+		},
+		bounds: func(filter *pb.Filter) (float64, float64, bool) {
+			return math.Inf(-1), filter.GetMaxPriceUsd(), filter.GetMaxPriceUsd() > 0
+		},
+	},
+	{
+		name: "cpu.number_of_cores",
+		key: func(laptop *pb.Laptop) float64 {
+			return float64(laptop.GetCpu().GetNumberOfCores())
+		},
+		bounds: func(filter *pb.Filter) (float64, float64, bool) {
+			return float64(filter.GetMinCpuCores()), math.Inf(1), filter.GetMinCpuCores() > 0
+		},
+	},
+	{
+		name: "cpu.min_ghz",
+		key: func(laptop *pb.Laptop) float64 {
+			return laptop.GetCpu().GetMinGhz()
+		},
+		bounds: func(filter *pb.Filter) (float64, float64, bool) {
+			return filter.GetMinCupGhb(), math.Inf(1), filter.GetMinCupGhb() > 0
+		},
+	},
+	{
+		name: "ram",
+		key: func(laptop *pb.Laptop) float64 {
+			return float64(units.MemoryBits(laptop.GetRam()))
+		},
+		bounds: func(filter *pb.Filter) (float64, float64, bool) {
+			minRam := units.MemoryBits(filter.GetMinRam())
+			return float64(minRam), math.Inf(1), minRam > 0
+		},
+	},
+}
+
+// indexEntryOf returns the entry of the laptop in the index of the field,
+// NaN keys are ordered first so that they can never break the order
+func indexEntryOf(field indexedField, laptop *pb.Laptop) indexEntry {
+	key := field.key(laptop)
+	if math.IsNaN(key) {
+		key = math.Inf(-1)
+	}
+
+	return indexEntry{key: key, id: laptop.GetId()}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/Adetunjii/go-grpc/pb"
+	"google.golang.org/protobuf/proto"
+	"hash/crc32"
+	"io"
+)
+
+// Every record of a laptop log is framed by a header holding the length of the
+// marshaled record and its CRC-32C checksum, both little endian:
+//
+//	| length (4 bytes) | checksum (4 bytes) | record (length bytes) |
+const (
+	recordHeaderSize = 8
+	maxRecordSize    = 64 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// CorruptLogException is returned when a record in the middle of a log is damaged,
+// unlike a torn record at the very end of it which is only left by an interrupted write
+var CorruptLogException = errors.New("laptop log is corrupt")
+
+// putRecord is the record storing the laptop, replacing the one with the same id
+func putRecord(laptop *pb.Laptop) *pb.LaptopLogRecord {
+	return &pb.LaptopLogRecord{Change: &pb.LaptopLogRecord_Put{Put: laptop}}
+}
+
+// appendRecord writes one framed record
+func appendRecord(w io.Writer, record *pb.LaptopLogRecord) error {
+	data, err := proto.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("cannot marshal log record: %w", err)
+	}
+
+	frame := make([]byte, recordHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(data, crcTable))
+	copy(frame[recordHeaderSize:], data)
+
+	_, err = w.Write(frame)
+	return err
+}
+
+// readRecords calls apply with every record of the log in order and returns the size of the valid part of the log.
+// A torn record at the end is reported with torn set to true and left out of the size,
+// so that the log can be truncated to it before appending again.
+func readRecords(r io.Reader, apply func(record *pb.LaptopLogRecord) error) (size int64, torn bool, err error) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, recordHeaderSize)
+
+	for {
+		n, err := io.ReadFull(reader, header)
+		if err == io.EOF {
+			return size, false, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return size, true, nil
+		}
+		if err != nil {
+			return size, false, err
+		}
+
+		length := binary.LittleEndian.Uint32(header[0:4])
+		checksum := binary.LittleEndian.Uint32(header[4:8])
+
+		if length > maxRecordSize {
+			// a length this large is garbage: a torn header if nothing follows, corruption otherwise
+			if _, err := reader.Peek(1); err == io.EOF {
+				return size, true, nil
+			}
+			return size, false, fmt.Errorf("%w: record at offset %d has an invalid length %d", CorruptLogException, size, length)
+		}
+
+		data := make([]byte, length)
+		_, err = io.ReadFull(reader, data)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return size, true, nil
+		}
+		if err != nil {
+			return size, false, err
+		}
+
+		if crc32.Checksum(data, crcTable) != checksum {
+			if _, err := reader.Peek(1); err == io.EOF {
+				return size, true, nil
+			}
+			return size, false, fmt.Errorf("%w: record at offset %d has a wrong checksum", CorruptLogException, size)
+		}
+
+		record := &pb.LaptopLogRecord{}
+		err = proto.Unmarshal(data, record)
+		if err != nil {
+			return size, false, fmt.Errorf("%w: cannot unmarshal record at offset %d: %v", CorruptLogException, size, err)
+		}
+
+		err = apply(record)
+		if err != nil {
+			return size, false, err
+		}
+
+		size += int64(n) + int64(length)
+	}
+}
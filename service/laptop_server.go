@@ -3,47 +3,96 @@ package service
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 	"log"
+	"math"
+	"strings"
+	"time"
 
+	"github.com/Adetunjii/go-grpc/expression"
 	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/validation"
 	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type LaptopServer struct {
-	LaptopStore LaptopStore
-	ImageStore  ImageStore
+	LaptopStore      LaptopStore
+	ImageStore       ImageStore
+	RatingStore      RatingStore
+	SavedSearchStore SavedSearchStore
+
+	// SimilarityWeights are used by FindSimilarLaptops when the request doesn't override them
+	SimilarityWeights SimilarityWeights
 	*pb.UnimplementedLaptopServiceServer
 }
 
-func NewLaptopServer(laptopStore LaptopStore, imageStore ImageStore) *LaptopServer {
+func NewLaptopServer(laptopStore LaptopStore, imageStore ImageStore, ratingStore RatingStore, savedSearchStore SavedSearchStore) *LaptopServer {
 	return &LaptopServer{
-		LaptopStore: laptopStore,
-		ImageStore:  imageStore,
+		LaptopStore:       laptopStore,
+		ImageStore:        imageStore,
+		RatingStore:       ratingStore,
+		SavedSearchStore:  savedSearchStore,
+		SimilarityWeights: DefaultSimilarityWeights(),
 	}
 }
 
 const maxImageSize = 1 << 20
 
+// range of the scores RateLaptop accepts
+const (
+	minLaptopScore = 1
+	maxLaptopScore = 10
+)
+
 // CreateLaptop
 // Unary RPC to create a new laptop
 func (server *LaptopServer) CreateLaptop(ctx context.Context, req *pb.CreatelaptopRequest) (*pb.CreateLaptopResponse, error) {
 	laptop := req.GetLaptop()
-	log.Printf("recieved a create laptop request wit id: %s", laptop.Id)
+	log.Printf("recieved a create laptop request wit id: %s", laptop.GetId())
+
+	id, err := server.saveLaptop(ctx, laptop)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pb.CreateLaptopResponse{
+		Id: id,
+	}
+
+	return res, nil
+}
+
+// saveLaptop assigns an id to the laptop if it has none and saves it to the store,
+// errors are returned as gRPC status errors
+func (server *LaptopServer) saveLaptop(ctx context.Context, laptop *pb.Laptop) (string, error) {
+	if laptop == nil {
+		return "", status.Errorf(codes.InvalidArgument, "laptop is required")
+	}
+
+	err := validation.ValidateLaptop(laptop)
+	if err != nil {
+		return "", invalidLaptopError(err)
+	}
 
 	if len(laptop.Id) > 0 {
 		_, err := uuid.Parse(laptop.Id)
 		if err != nil {
-			return nil, status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
+			return "", status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
 		}
 	} else {
 		// generate new id
 		id, err := uuid.NewRandom()
 		if err != nil {
-			return nil, status.Errorf(codes.Internal, "cannot generate a new laptop id: %v", err)
+			return "", status.Errorf(codes.Internal, "cannot generate a new laptop id: %v", err)
 		}
 		laptop.Id = id.String()
 	}
@@ -51,23 +100,58 @@ func (server *LaptopServer) CreateLaptop(ctx context.Context, req *pb.Createlapt
 	//time.Sleep(6 * time.Second)
 
 	if err := contextError(ctx); err != nil {
-		return nil, err
+		return "", err
 	}
 
-	err := server.LaptopStore.Save(laptop)
+	err = server.LaptopStore.Save(laptop)
 	if err != nil {
 		code := codes.Internal
 		if errors.Is(err, DuplicateException) {
 			code = codes.AlreadyExists
 		}
-		return nil, status.Errorf(code, "cannot save laptop to the store %v", err)
+		return "", status.Errorf(code, "cannot save laptop to the store %v", err)
 	}
 	log.Printf("saved laptop with id: %s", laptop.Id)
-	res := &pb.CreateLaptopResponse{
-		Id: laptop.Id,
+
+	return laptop.Id, nil
+}
+
+// invalidLaptopError converts validation errors to an InvalidArgument status
+// carrying a BadRequest detail with every offending field
+func invalidLaptopError(err error) error {
+	var violations validation.Errors
+	if !errors.As(err, &violations) {
+		return status.Errorf(codes.InvalidArgument, "invalid laptop: %v", err)
 	}
 
-	return res, nil
+	st := status.Newf(codes.InvalidArgument, "invalid laptop fields %v: %v", violations.Fields(), err)
+
+	badRequest := &errdetails.BadRequest{}
+	for _, violation := range violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       violation.Field,
+			Description: violation.Description,
+		})
+	}
+
+	detailed, detailErr := st.WithDetails(badRequest)
+	if detailErr != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// writeErrorCode maps a store error of a write on an existing laptop to a gRPC code
+func writeErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, NotFoundException):
+		return codes.NotFound
+	case errors.Is(err, VersionMismatchException):
+		return codes.Aborted
+	default:
+		return codes.Internal
+	}
 }
 
 func contextError(ctx context.Context) error {
@@ -83,12 +167,15 @@ func contextError(ctx context.Context) error {
 	return nil
 }
 
+// returned by a search callback to stop the search early
+var errSearchLimitReached = errors.New("search limit reached")
+
 func (server *LaptopServer) SearchLaptop(req *pb.SearchLaptopRequest, stream pb.LaptopService_SearchLaptopServer) error {
 
 	filter := req.GetFilter()
-	log.Printf("received a search laptop filter with %v", filter)
+	log.Printf("received a search laptop filter with %v and query %q", filter, req.GetQuery())
 
-	err := server.LaptopStore.Search(stream.Context(), filter, func(laptop *pb.Laptop) error {
+	send := func(laptop *pb.Laptop) error {
 		res := &pb.SearchLaptopResponse{Laptop: laptop}
 
 		err := stream.Send(res)
@@ -98,89 +185,1245 @@ func (server *LaptopServer) SearchLaptop(req *pb.SearchLaptopRequest, stream pb.
 
 		log.Printf("sent laptop with id %s", laptop.GetId())
 		return nil
+	}
+
+	expr, err := parseFilterExpression(filter, req.GetFilterExpression())
+	if err != nil {
+		return err
+	}
+
+	maxResults := int(req.GetMaxResults())
+	query := req.GetQuery()
+
+	// relevance of the laptops matching the query, by id
+	relevance := make(map[string]float64)
+
+	search := func(found func(laptop *pb.Laptop) error) error {
+		if expr != nil {
+			matched := found
+			found = func(laptop *pb.Laptop) error {
+				if !expr.Evaluate(laptop) {
+					return nil
+				}
+				return matched(laptop)
+			}
+		}
+
+		if query == "" {
+			return server.LaptopStore.Search(stream.Context(), filter, found)
+		}
+
+		return server.LaptopStore.SearchText(stream.Context(), query, filter, func(laptop *pb.Laptop, score float64) error {
+			relevance[laptop.GetId()] = score
+			return found(laptop)
+		})
+	}
+
+	var order laptopLess
+	switch {
+	case req.GetSortKey() != pb.SearchLaptopRequest_UNSORTED:
+		order = laptopOrder(req.GetSortKey(), req.GetSortDirection())
+	case query != "":
+		order = func(a *pb.Laptop, b *pb.Laptop) bool {
+			if c := compareFloat(relevance[a.GetId()], relevance[b.GetId()]); c != 0 {
+				return c > 0
+			}
+			return a.GetId() < b.GetId()
+		}
+	}
+
+	if order == nil {
+		sent := 0
+		err := search(func(laptop *pb.Laptop) error {
+			if maxResults > 0 && sent >= maxResults {
+				return errSearchLimitReached
+			}
+
+			sent++
+			return send(laptop)
+		})
+
+		if err != nil && !errors.Is(err, errSearchLimitReached) {
+			return status.Errorf(codes.Internal, "unexpected error: %v", err)
+		}
+
+		return server.sendExplanation(req, expr, stream)
+	}
+
+	// only the best max_results laptops are kept while searching, they are sent once the search is done
+	top := newTopLaptops(order, maxResults)
+	err = search(func(laptop *pb.Laptop) error {
+		top.add(laptop)
+		return nil
 	})
 
 	if err != nil {
 		return status.Errorf(codes.Internal, "unexpected error: %v", err)
 	}
 
+	for _, laptop := range top.sorted() {
+		err := send(laptop)
+		if err != nil {
+			return status.Errorf(codes.Internal, "unexpected error: %v", err)
+		}
+	}
+
+	return server.sendExplanation(req, expr, stream)
+}
+
+// sendExplanation ends the results of an explained search with a message carrying the explanation only
+func (server *LaptopServer) sendExplanation(req *pb.SearchLaptopRequest, expr expression.Expression, stream pb.LaptopService_SearchLaptopServer) error {
+	if !req.GetExplain() {
+		return nil
+	}
+
+	explanation, err := server.explainSearch(stream.Context(), req.GetFilter(), req.GetQuery(), expr, defaultNearMisses)
+	if err != nil {
+		return err
+	}
+
+	err = stream.Send(&pb.SearchLaptopResponse{Explanation: explanation})
+	if err != nil {
+		return status.Errorf(codes.Internal, "unexpected error: %v", err)
+	}
+
+	log.Printf("sent search explanation: %d laptops scanned, %d matched", explanation.GetScannedCount(), explanation.GetMatchedCount())
 	return nil
 }
 
-//////////////////////////////////////////
-/// CLIENT SIDE STREAMING             ///
-/////////////////////////////////////////
-func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServer) error {
-	req, err := stream.Recv()
+// parseFilterExpression returns the parsed expression of a search, or nil if there is none
+func parseFilterExpression(filter *pb.Filter, source string) (expression.Expression, error) {
+	if source == "" {
+		return nil, nil
+	}
+
+	if filter != nil {
+		return nil, status.Error(codes.InvalidArgument, "filter and filter_expression cannot be used together")
+	}
+
+	expr, err := expression.Parse(source)
 	if err != nil {
-		return logError(status.Errorf(codes.Unknown, "cannot receive image info"))
+		return nil, status.Errorf(codes.InvalidArgument, "invalid filter expression: %v", err)
 	}
 
-	laptopID := req.GetInfo().GetLaptopId()
-	imageType := req.GetInfo().GetImageType()
-	log.Printf("receive an upload-image request for laptop %s with image type %s", laptopID, imageType)
+	log.Printf("parsed filter expression %s", expr)
+	return expr, nil
+}
 
-	laptop, err := server.LaptopStore.FindById(laptopID)
+const (
+	defaultNearMisses = 10
+	maxNearMisses     = 100
+)
+
+// ExplainSearch
+// Unary RPC telling how many laptops each criterion of a search rejected, and which laptops failed only one of them
+func (server *LaptopServer) ExplainSearch(ctx context.Context, req *pb.ExplainSearchRequest) (*pb.ExplainSearchResponse, error) {
+	filter := req.GetFilter()
+	log.Printf("received an explain search filter with %v and query %q", filter, req.GetQuery())
+
+	expr, err := parseFilterExpression(filter, req.GetFilterExpression())
 	if err != nil {
-		return logError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+		return nil, err
 	}
 
-	if laptop == nil {
-		return logError(status.Errorf(codes.InvalidArgument, "laptop %s doesn't exist", laptopID))
+	limit := int(req.GetMaxNearMisses())
+	if limit == 0 {
+		limit = defaultNearMisses
+	}
+	if limit > maxNearMisses {
+		limit = maxNearMisses
 	}
 
-	imageData := bytes.Buffer{}
-	imageSize := 0
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
 
-	for {
+	explanation, err := server.explainSearch(ctx, filter, req.GetQuery(), expr, limit)
+	if err != nil {
+		return nil, err
+	}
 
-		if err = contextError(stream.Context()); err != nil {
-			return err
+	res := &pb.ExplainSearchResponse{
+		Explanation: explanation,
+	}
+
+	return res, nil
+}
+
+// explainSearch runs every criterion of the search on the whole catalog
+func (server *LaptopServer) explainSearch(
+	ctx context.Context,
+	filter *pb.Filter,
+	query string,
+	expr expression.Expression,
+	maxNearMisses int,
+) (*pb.SearchExplanation, error) {
+	explainer := newSearchExplainer(filter, maxNearMisses)
+
+	if expr != nil {
+		explainer.addCheck("filter_expression", expr.Evaluate)
+	}
+
+	if query != "" {
+		matches := make(map[string]bool)
+		err := server.LaptopStore.SearchText(ctx, query, nil, func(laptop *pb.Laptop, _ float64) error {
+			matches[laptop.GetId()] = true
+			return nil
+		})
+
+		if err != nil {
+			if err := contextError(ctx); err != nil {
+				return nil, err
+			}
+			return nil, status.Errorf(codes.Internal, "cannot search laptops: %v", err)
 		}
 
-		log.Print("waiting for data chunks")
+		explainer.addCheck("query", func(laptop *pb.Laptop) bool {
+			return matches[laptop.GetId()]
+		})
+	}
 
-		req, err := stream.Recv()
-		if err == io.EOF {
-			log.Print("no more data")
-			break
+	err := server.LaptopStore.Search(ctx, nil, func(laptop *pb.Laptop) error {
+		explainer.add(laptop)
+		return nil
+	})
+
+	if err != nil {
+		if err := contextError(ctx); err != nil {
+			return nil, err
 		}
+		return nil, status.Errorf(codes.Internal, "cannot search laptops: %v", err)
+	}
 
-		if err != nil {
-			return logError(status.Errorf(codes.Unknown, "cannot receive chunk data: %v", err))
+	return explainer.toProto(), nil
+}
+
+// SearchFacets
+// Unary RPC counting the laptops matching a filter in the buckets of every facet
+func (server *LaptopServer) SearchFacets(ctx context.Context, req *pb.SearchFacetsRequest) (*pb.SearchFacetsResponse, error) {
+	filter := req.GetFilter()
+	log.Printf("received a search facets filter with %v", filter)
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	// the buckets are filled while searching, so the store is locked exactly as long as for a search
+	facets := newLaptopFacets()
+	err := server.LaptopStore.Search(ctx, filter, func(laptop *pb.Laptop) error {
+		facets.add(laptop)
+		return nil
+	})
+
+	if err != nil {
+		if err := contextError(ctx); err != nil {
+			return nil, err
 		}
+		return nil, status.Errorf(codes.Internal, "cannot search laptops: %v", err)
+	}
 
-		chunk := req.GetChunkData()
-		size := len(chunk)
+	res := &pb.SearchFacetsResponse{
+		TotalCount: facets.total,
+		Facets:     facets.toProto(),
+	}
 
-		imageSize += size
-		if imageSize > maxImageSize {
-			return logError(status.Errorf(codes.InvalidArgument, "image is too large: %d > %d", imageSize, maxImageSize))
+	return res, nil
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// ListLaptops
+// Unary RPC returning one page of laptops ordered by id
+func (server *LaptopServer) ListLaptops(ctx context.Context, req *pb.ListLaptopsRequest) (*pb.ListLaptopsResponse, error) {
+	log.Printf("received a list laptops request with page size %d", req.GetPageSize())
+
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	afterID, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page token: %v", err)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	// fetch one extra laptop to know whether there is a next page
+	laptops, err := server.LaptopStore.List(ctx, afterID, pageSize+1)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot list laptops: %v", err)
+	}
+
+	res := &pb.ListLaptopsResponse{}
+	if len(laptops) > pageSize {
+		laptops = laptops[:pageSize]
+		res.NextPageToken = encodePageToken(laptops[pageSize-1].GetId())
+	}
+	res.Laptops = laptops
+
+	return res, nil
+}
+
+// page tokens are opaque to clients, they encode the id of the last laptop of the previous page
+func encodePageToken(lastID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(lastID))
+}
+
+func decodePageToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+
+	lastID, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = uuid.Parse(string(lastID))
+	if err != nil {
+		return "", err
+	}
+
+	return string(lastID), nil
+}
+
+// WatchLaptops
+// Server side streaming RPC pushing every change to the laptops matching the optional filter,
+// a change moving a laptop out of the filter is pushed as well so that watchers can drop it
+func (server *LaptopServer) WatchLaptops(req *pb.WatchRequest, stream pb.LaptopService_WatchLaptopsServer) error {
+	filter := req.GetFilter()
+	log.Printf("received a watch laptops request with filter %v", filter)
+
+	events := server.LaptopStore.Watch(stream.Context())
+	for event := range events {
+		previous := event.GetPreviousLaptop()
+		if !isQualified(filter, event.GetLaptop()) && (previous == nil || !isQualified(filter, previous)) {
+			continue
 		}
 
-		_, err = imageData.Write(chunk)
+		err := stream.Send(event)
 		if err != nil {
-			return logError(status.Errorf(codes.Internal, "cannot write chunk data: %v", err))
+			return logError(status.Errorf(codes.Unknown, "cannot send event: %v", err))
 		}
 	}
 
-	imageID, err := server.ImageStore.Save(laptopID, imageType, imageData)
+	if err := contextError(stream.Context()); err != nil {
+		return err
+	}
+
+	return logError(status.Errorf(codes.ResourceExhausted, "watcher fell too far behind, watch again to resume"))
+}
+
+// GetLaptop
+// Unary RPC to fetch a single laptop by its id
+func (server *LaptopServer) GetLaptop(ctx context.Context, req *pb.GetLaptopRequest) (*pb.GetLaptopResponse, error) {
+	laptopID := req.GetId()
+	log.Printf("received a get laptop request with id: %s", laptopID)
+
+	_, err := uuid.Parse(laptopID)
 	if err != nil {
-		return logError(status.Errorf(codes.Internal, "cannot save image to the store: %v", err))
+		return nil, status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
 	}
 
-	res := &pb.UploadImageResponse{
-		Id:   imageID,
-		Size: uint32(imageSize),
+	if err := contextError(ctx); err != nil {
+		return nil, err
 	}
 
-	err = stream.SendAndClose(res)
+	laptop, err := server.LaptopStore.FindById(laptopID)
 	if err != nil {
-		return logError(status.Errorf(codes.Unknown, "cannot send response %v", err))
+		return nil, status.Errorf(codes.Internal, "cannot find laptop: %v", err)
 	}
 
-	log.Printf("image successfully saved %s, %d", imageID, imageSize)
-	return nil
+	if laptop == nil {
+		return nil, status.Errorf(codes.NotFound, "laptop %s doesn't exist", laptopID)
+	}
+
+	res := &pb.GetLaptopResponse{
+		Laptop: laptop,
+	}
+
+	return res, nil
+}
+
+// UpdateLaptop
+// Unary RPC to partially update a laptop, only the fields in the update mask are changed
+func (server *LaptopServer) UpdateLaptop(ctx context.Context, req *pb.UpdateLaptopRequest) (*pb.UpdateLaptopResponse, error) {
+	laptop := req.GetLaptop()
+	log.Printf("received an update laptop request with id: %s, mask: %v", laptop.GetId(), req.GetUpdateMask().GetPaths())
+
+	_, err := uuid.Parse(laptop.GetId())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
+	}
+
+	err = validateLaptopMask(req.GetUpdateMask())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	updated, err := server.updateLaptop(laptop, req.GetUpdateMask(), req.GetExpectedVersion())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("updated laptop with id: %s", updated.Id)
+
+	res := &pb.UpdateLaptopResponse{
+		Laptop: updated,
+	}
+
+	return res, nil
+}
+
+// updateLaptop checks that the mask leaves the stored laptop valid, then updates it.
+// The laptop that was checked is the one updated: without an expected version,
+// a laptop changed in between is checked again.
+func (server *LaptopServer) updateLaptop(laptop *pb.Laptop, mask *fieldmaskpb.FieldMask, expectedVersion uint64) (*pb.Laptop, error) {
+	for {
+		existing, err := server.LaptopStore.FindById(laptop.GetId())
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "cannot find laptop: %v", err)
+		}
+
+		if existing == nil {
+			return nil, status.Errorf(codes.NotFound, "cannot update laptop: %v", NotFoundException)
+		}
+
+		if expectedVersion != 0 && existing.GetVersion() != expectedVersion {
+			return nil, status.Errorf(codes.Aborted, "cannot update laptop: %v", VersionMismatchException)
+		}
+
+		// the mask may leave the laptop invalid even when every field it names is valid on its own
+		merged := proto.Clone(existing).(*pb.Laptop)
+		applyLaptopMask(merged, laptop, mask)
+
+		err = validation.ValidateLaptop(merged)
+		if err != nil {
+			return nil, invalidLaptopError(err)
+		}
+
+		version := expectedVersion
+		if version == 0 {
+			version = existing.GetVersion()
+		}
+
+		updated, err := server.LaptopStore.Update(laptop, mask, version)
+		if errors.Is(err, VersionMismatchException) && expectedVersion == 0 {
+			continue
+		}
+
+		if err != nil {
+			return nil, status.Errorf(writeErrorCode(err), "cannot update laptop: %v", err)
+		}
+
+		return updated, nil
+	}
+}
+
+// DeleteLaptop
+// Unary RPC to move a laptop to the trash, its images are kept until the laptop is purged
+func (server *LaptopServer) DeleteLaptop(ctx context.Context, req *pb.DeleteLaptopRequest) (*pb.DeleteLaptopResponse, error) {
+	laptopID := req.GetId()
+	log.Printf("received a delete laptop request with id: %s", laptopID)
+
+	_, err := uuid.Parse(laptopID)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	err = server.LaptopStore.Delete(laptopID, req.GetExpectedVersion())
+	if err != nil {
+		return nil, status.Errorf(writeErrorCode(err), "cannot delete laptop: %v", err)
+	}
+	log.Printf("deleted laptop with id: %s", laptopID)
+
+	return &pb.DeleteLaptopResponse{}, nil
+}
+
+// ListDeletedLaptops
+// Server side streaming RPC sending every laptop in the trash
+func (server *LaptopServer) ListDeletedLaptops(req *pb.ListDeletedLaptopsRequest, stream pb.LaptopService_ListDeletedLaptopsServer) error {
+	log.Print("received a list deleted laptops request")
+
+	err := server.LaptopStore.ListDeleted(stream.Context(), func(laptop *pb.Laptop) error {
+		return stream.Send(&pb.ListDeletedLaptopsResponse{Laptop: laptop})
+	})
+
+	if err != nil {
+		return status.Errorf(codes.Internal, "unexpected error: %v", err)
+	}
+
+	return nil
+}
+
+// RestoreLaptop
+// Unary RPC to take a deleted laptop out of the trash
+func (server *LaptopServer) RestoreLaptop(ctx context.Context, req *pb.RestoreLaptopRequest) (*pb.RestoreLaptopResponse, error) {
+	laptopID := req.GetId()
+	log.Printf("received a restore laptop request with id: %s", laptopID)
+
+	_, err := uuid.Parse(laptopID)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	laptop, err := server.LaptopStore.Restore(laptopID, req.GetExpectedVersion())
+	if err != nil {
+		return nil, status.Errorf(writeErrorCode(err), "cannot restore laptop: %v", err)
+	}
+	log.Printf("restored laptop with id: %s", laptopID)
+
+	res := &pb.RestoreLaptopResponse{
+		Laptop: laptop,
+	}
+
+	return res, nil
+}
+
+// PurgeDeletedLaptops permanently removes the laptops that have been in the trash
+// for longer than the retention period, together with all their images
+func (server *LaptopServer) PurgeDeletedLaptops(retention time.Duration) ([]string, error) {
+	return server.purgeLaptops(time.Now().Add(-retention))
+}
+
+// purgeLaptops permanently removes the laptops deleted before the given time and their images
+func (server *LaptopServer) purgeLaptops(deletedBefore time.Time) ([]string, error) {
+	purged, err := server.LaptopStore.Purge(deletedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("cannot purge deleted laptops: %w", err)
+	}
+
+	if server.ImageStore != nil {
+		// the laptops are gone already, so keep deleting the images of the others
+		// rather than leaving them without a laptop
+		failed := []string{}
+		var imageErr error
+		for _, laptopID := range purged {
+			err = server.ImageStore.DeleteByLaptopID(laptopID)
+			if err != nil {
+				log.Printf("cannot delete images of purged laptop %s: %v", laptopID, err)
+				failed = append(failed, laptopID)
+				if imageErr == nil {
+					imageErr = err
+				}
+			}
+		}
+
+		if imageErr != nil {
+			return purged, fmt.Errorf("cannot delete images of laptops %v: %w", failed, imageErr)
+		}
+	}
+
+	if len(purged) > 0 {
+		log.Printf("purged %d deleted laptops", len(purged))
+	}
+
+	return purged, nil
+}
+
+//////////////////////////////////////////
+/// CLIENT SIDE STREAMING             ///
+/////////////////////////////////////////
+func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return logError(status.Errorf(codes.Unknown, "cannot receive image info"))
+	}
+
+	laptopID := req.GetInfo().GetLaptopId()
+	imageType := req.GetInfo().GetImageType()
+	log.Printf("receive an upload-image request for laptop %s with image type %s", laptopID, imageType)
+
+	laptop, err := server.LaptopStore.FindById(laptopID)
+	if err != nil {
+		return logError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+	}
+
+	if laptop == nil {
+		return logError(status.Errorf(codes.InvalidArgument, "laptop %s doesn't exist", laptopID))
+	}
+
+	imageData := bytes.Buffer{}
+	imageSize := 0
+
+	for {
+
+		if err = contextError(stream.Context()); err != nil {
+			return err
+		}
+
+		log.Print("waiting for data chunks")
+
+		req, err := stream.Recv()
+		if err == io.EOF {
+			log.Print("no more data")
+			break
+		}
+
+		if err != nil {
+			return logError(status.Errorf(codes.Unknown, "cannot receive chunk data: %v", err))
+		}
+
+		chunk := req.GetChunkData()
+		size := len(chunk)
+
+		imageSize += size
+		if imageSize > maxImageSize {
+			return logError(status.Errorf(codes.InvalidArgument, "image is too large: %d > %d", imageSize, maxImageSize))
+		}
+
+		_, err = imageData.Write(chunk)
+		if err != nil {
+			return logError(status.Errorf(codes.Internal, "cannot write chunk data: %v", err))
+		}
+	}
+
+	imageID, err := server.ImageStore.Save(laptopID, imageType, imageData)
+	if err != nil {
+		return logError(status.Errorf(codes.Internal, "cannot save image to the store: %v", err))
+	}
+
+	res := &pb.UploadImageResponse{
+		Id:   imageID,
+		Size: uint32(imageSize),
+	}
+
+	err = stream.SendAndClose(res)
+	if err != nil {
+		return logError(status.Errorf(codes.Unknown, "cannot send response %v", err))
+	}
+
+	log.Printf("image successfully saved %s, %d", imageID, imageSize)
+	return nil
+}
+
+// CreateLaptops
+// Client side streaming RPC to create many laptops at once,
+// a failing laptop is reported in its result and doesn't abort the rest of the batch
+func (server *LaptopServer) CreateLaptops(stream pb.LaptopService_CreateLaptopsServer) error {
+	res := &pb.CreateLaptopsResponse{}
+
+	for index := uint32(0); ; index++ {
+		err := contextError(stream.Context())
+		if err != nil {
+			return err
+		}
+
+		req, err := stream.Recv()
+		if err == io.EOF {
+			log.Print("no more data")
+			break
+		}
+
+		if err != nil {
+			return logError(status.Errorf(codes.Unknown, "cannot receive stream request: %v", err))
+		}
+
+		id, err := server.saveLaptop(stream.Context(), req.GetLaptop())
+		st := status.Convert(err)
+		if st.Code() == codes.Canceled || st.Code() == codes.DeadlineExceeded {
+			return err
+		}
+
+		if err == nil {
+			res.CreatedCount++
+		}
+
+		res.Results = append(res.Results, &pb.CreateLaptopResult{
+			Index:   index,
+			Id:      id,
+			Code:    uint32(st.Code()),
+			Message: st.Message(),
+		})
+	}
+
+	err := stream.SendAndClose(res)
+	if err != nil {
+		return logError(status.Errorf(codes.Unknown, "cannot send response %v", err))
+	}
+
+	log.Printf("created %d of %d laptops", res.CreatedCount, len(res.Results))
+	return nil
+}
+
+//////////////////////////////////////////
+/// BIDIRECTIONAL STREAMING           ///
+/////////////////////////////////////////
+func (server *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer) error {
+	for {
+		err := contextError(stream.Context())
+		if err != nil {
+			return err
+		}
+
+		req, err := stream.Recv()
+		if err == io.EOF {
+			log.Print("no more data")
+			break
+		}
+
+		if err != nil {
+			return logError(status.Errorf(codes.Unknown, "cannot receive stream request: %v", err))
+		}
+
+		laptopID := req.GetLaptopId()
+		score := req.GetScore()
+		log.Printf("received a rate-laptop request: id = %s, score = %.2f", laptopID, score)
+
+		// a single NaN or infinite score would spoil the average of the laptop for good
+		if math.IsNaN(score) || score < minLaptopScore || score > maxLaptopScore {
+			return logError(status.Errorf(codes.InvalidArgument, "score must be between %d and %d, got %v", minLaptopScore, maxLaptopScore, score))
+		}
+
+		laptop, err := server.LaptopStore.FindById(laptopID)
+		if err != nil {
+			return logError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+		}
+
+		if laptop == nil {
+			return logError(status.Errorf(codes.NotFound, "laptop %s doesn't exist", laptopID))
+		}
+
+		rating, err := server.RatingStore.Add(laptopID, score)
+		if err != nil {
+			return logError(status.Errorf(codes.Internal, "cannot add rating to the store: %v", err))
+		}
+
+		res := &pb.RateLaptopResponse{
+			LaptopId:     laptopID,
+			RatedCount:   rating.Count,
+			AverageScore: rating.Sum / float64(rating.Count),
+		}
+
+		err = stream.Send(res)
+		if err != nil {
+			return logError(status.Errorf(codes.Unknown, "cannot send stream response: %v", err))
+		}
+	}
+
+	return nil
+}
+
+// currentUser returns the claims of the authenticated caller
+func currentUser(ctx context.Context) (*UserClaims, error) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "this RPC requires an authenticated user")
+	}
+
+	return claims, nil
+}
+
+// SaveSearch
+// Unary RPC to save a filter for the authenticated user, who is then alerted of new matching laptops
+func (server *LaptopServer) SaveSearch(ctx context.Context, req *pb.SaveSearchRequest) (*pb.SaveSearchResponse, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("received a save search request from %s with filter %v", user.Username, req.GetFilter())
+
+	name := strings.TrimSpace(req.GetName())
+	if name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "saved search name must not be empty")
+	}
+
+	// a search without any criterion would alert on every created or updated laptop
+	if proto.Size(req.GetFilter()) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "saved search filter must not be empty")
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	search := &pb.SavedSearch{
+		Id:        uuid.New().String(),
+		Name:      name,
+		Filter:    req.GetFilter(),
+		CreatedAt: timestamppb.Now(),
+	}
+
+	err = server.SavedSearchStore.Save(user.Username, search)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot save search: %v", err)
+	}
+
+	res := &pb.SaveSearchResponse{
+		SavedSearch: search,
+	}
+
+	return res, nil
+}
+
+// ListSavedSearches
+// Unary RPC returning the searches saved by the authenticated user
+func (server *LaptopServer) ListSavedSearches(ctx context.Context, req *pb.ListSavedSearchesRequest) (*pb.ListSavedSearchesResponse, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("received a list saved searches request from %s", user.Username)
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	searches, err := server.SavedSearchStore.List(user.Username)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot list saved searches: %v", err)
+	}
+
+	res := &pb.ListSavedSearchesResponse{
+		SavedSearches: searches,
+	}
+
+	return res, nil
+}
+
+// DeleteSavedSearch
+// Unary RPC to delete one of the searches saved by the authenticated user
+func (server *LaptopServer) DeleteSavedSearch(ctx context.Context, req *pb.DeleteSavedSearchRequest) (*pb.DeleteSavedSearchResponse, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchID := req.GetId()
+	log.Printf("received a delete saved search request from %s with id: %s", user.Username, searchID)
+
+	_, err = uuid.Parse(searchID)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "saved search ID is not a valid UUID: %v", err)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	err = server.SavedSearchStore.Delete(user.Username, searchID)
+	if err != nil {
+		if errors.Is(err, NotFoundException) {
+			return nil, status.Errorf(codes.NotFound, "saved search %s doesn't exist", searchID)
+		}
+		return nil, status.Errorf(codes.Internal, "cannot delete saved search: %v", err)
+	}
+
+	return &pb.DeleteSavedSearchResponse{}, nil
+}
+
+// StreamSearchAlerts
+// Server side streaming RPC pushing every created, updated or restored laptop
+// that satisfies one of the searches saved by the authenticated user
+func (server *LaptopServer) StreamSearchAlerts(req *pb.StreamSearchAlertsRequest, stream pb.LaptopService_StreamSearchAlertsServer) error {
+	user, err := currentUser(stream.Context())
+	if err != nil {
+		return err
+	}
+	log.Printf("received a stream search alerts request from %s", user.Username)
+
+	events := server.LaptopStore.Watch(stream.Context())
+	for event := range events {
+		switch event.GetType() {
+		case pb.LaptopEvent_CREATED, pb.LaptopEvent_UPDATED, pb.LaptopEvent_RESTORED:
+		default:
+			continue
+		}
+
+		// searches are read for every event so that new ones apply right away
+		searches, err := server.SavedSearchStore.List(user.Username)
+		if err != nil {
+			return logError(status.Errorf(codes.Internal, "cannot list saved searches: %v", err))
+		}
+
+		alert := &pb.SearchAlert{
+			Laptop:     event.GetLaptop(),
+			OccurredAt: event.GetOccurredAt(),
+		}
+		for _, search := range searches {
+			if isQualified(search.GetFilter(), event.GetLaptop()) {
+				alert.SavedSearches = append(alert.SavedSearches, search)
+			}
+		}
+
+		if len(alert.SavedSearches) == 0 {
+			continue
+		}
+
+		err = stream.Send(alert)
+		if err != nil {
+			return logError(status.Errorf(codes.Unknown, "cannot send alert: %v", err))
+		}
+	}
+
+	if err := contextError(stream.Context()); err != nil {
+		return err
+	}
+
+	return logError(status.Errorf(codes.ResourceExhausted, "alert stream fell too far behind, stream again to resume"))
+}
+
+const (
+	defaultSimilarLaptops = 5
+	maxSimilarLaptops     = 100
+)
+
+// FindSimilarLaptops
+// Unary RPC returning the k laptops closest to a given one, the laptop itself excluded
+func (server *LaptopServer) FindSimilarLaptops(ctx context.Context, req *pb.FindSimilarLaptopsRequest) (*pb.FindSimilarLaptopsResponse, error) {
+	laptopID := req.GetLaptopId()
+	log.Printf("received a find similar laptops request with id: %s and k: %d", laptopID, req.GetK())
+
+	_, err := uuid.Parse(laptopID)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
+	}
+
+	k := int(req.GetK())
+	if k == 0 {
+		k = defaultSimilarLaptops
+	}
+	if k > maxSimilarLaptops {
+		k = maxSimilarLaptops
+	}
+
+	weights, err := server.SimilarityWeights.merge(req.GetWeights())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid weights: %v", err)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	target, err := server.LaptopStore.FindById(laptopID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot find laptop: %v", err)
+	}
+
+	if target == nil {
+		return nil, status.Errorf(codes.NotFound, "laptop %s doesn't exist", laptopID)
+	}
+
+	candidates := []*similarityCandidate{}
+	err = server.LaptopStore.Search(ctx, nil, func(laptop *pb.Laptop) error {
+		if laptop.GetId() != laptopID {
+			candidates = append(candidates, &similarityCandidate{laptop: laptop, vector: featureVector(laptop)})
+		}
+		return nil
+	})
+
+	if err != nil {
+		if err := contextError(ctx); err != nil {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.Internal, "cannot search laptops: %v", err)
+	}
+
+	res := &pb.FindSimilarLaptopsResponse{}
+	for _, candidate := range nearestLaptops(featureVector(target), candidates, weights, k) {
+		res.SimilarLaptops = append(res.SimilarLaptops, &pb.SimilarLaptop{
+			Laptop:   candidate.laptop,
+			Distance: candidate.distance,
+		})
+	}
+
+	return res, nil
+}
+
+// ExportCatalog
+// Server streaming RPC sending every laptop of the catalog along with the metadata of its images,
+// the laptops in the trash are not exported
+func (server *LaptopServer) ExportCatalog(req *pb.ExportCatalogRequest, stream pb.LaptopService_ExportCatalogServer) error {
+	log.Print("received an export catalog request")
+
+	exported := 0
+	err := server.LaptopStore.Search(stream.Context(), nil, func(laptop *pb.Laptop) error {
+		entry, err := server.catalogEntry(laptop)
+		if err != nil {
+			return err
+		}
+
+		err = stream.Send(&pb.ExportCatalogResponse{Entry: entry})
+		if err != nil {
+			return err
+		}
+
+		exported++
+		return nil
+	})
+
+	if err != nil {
+		if err := contextError(stream.Context()); err != nil {
+			return err
+		}
+		return logError(status.Errorf(codes.Internal, "cannot export catalog: %v", err))
+	}
+
+	log.Printf("exported %d laptops", exported)
+	return nil
+}
+
+func (server *LaptopServer) catalogEntry(laptop *pb.Laptop) (*pb.CatalogEntry, error) {
+	entry := &pb.CatalogEntry{Laptop: laptop}
+	if server.ImageStore == nil {
+		return entry, nil
+	}
+
+	images, err := server.ImageStore.FindByLaptopID(laptop.GetId())
+	if err != nil {
+		return nil, fmt.Errorf("cannot find images of laptop %s: %w", laptop.GetId(), err)
+	}
+
+	for _, image := range images {
+		entry.Images = append(entry.Images, &pb.ImageMetadata{
+			Id:        image.ID,
+			ImageType: image.Type,
+			Path:      image.Path,
+		})
+	}
+
+	return entry, nil
+}
+
+// ImportCatalog
+// Client streaming RPC rebuilding the catalog from the entries of an export
+func (server *LaptopServer) ImportCatalog(stream pb.LaptopService_ImportCatalogServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return logError(status.Errorf(codes.Unknown, "cannot receive import info: %v", err))
+	}
+
+	mode := req.GetInfo().GetMode()
+	if mode != pb.ImportCatalogInfo_MERGE && mode != pb.ImportCatalogInfo_REPLACE {
+		return logError(status.Errorf(codes.InvalidArgument, "import mode must be MERGE or REPLACE"))
+	}
+	log.Printf("receive an import catalog request in %s mode", mode)
+
+	// the whole catalog is received and checked before changing anything,
+	// so that a bad entry or a broken stream leaves the stores untouched
+	entries := []*pb.CatalogEntry{}
+	imported := make(map[string]bool)
+
+	for {
+		if err := contextError(stream.Context()); err != nil {
+			return err
+		}
+
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return logError(status.Errorf(codes.Unknown, "cannot receive catalog entry: %v", err))
+		}
+
+		entry := req.GetEntry()
+		err = validateCatalogEntry(entry, imported)
+		if err != nil {
+			return logError(err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	// the image store checks every image before recording any, so an invalid path changes nothing either.
+	// Past this point only a store failure stops the import, leaving the catalog partly imported:
+	// the error tells how many laptops were imported
+	err = server.importCatalogImages(entries)
+	if err != nil {
+		return logError(err)
+	}
+
+	for i, entry := range entries {
+		err := server.importCatalogLaptop(entry.GetLaptop())
+		if err != nil {
+			return logError(status.Errorf(status.Code(err), "%d of %d laptops imported: %v", i, len(entries), status.Convert(err).Message()))
+		}
+	}
+
+	// nothing is removed before every laptop is imported
+	removed := 0
+	if mode == pb.ImportCatalogInfo_REPLACE {
+		removed, err = server.removeOtherLaptops(stream.Context(), entries)
+		if err != nil {
+			return logError(status.Errorf(status.Code(err), "%d laptops imported: %v", len(entries), status.Convert(err).Message()))
+		}
+	}
+
+	res := &pb.ImportCatalogResponse{
+		ImportedCount: uint32(len(entries)),
+		RemovedCount:  uint32(removed),
+	}
+
+	err = stream.SendAndClose(res)
+	if err != nil {
+		return logError(status.Errorf(codes.Unknown, "cannot send response: %v", err))
+	}
+
+	log.Printf("imported %d laptops, removed %d", len(entries), removed)
+	return nil
+}
+
+// validateCatalogEntry checks an entry to import, imported holds the ids of the laptops and images already received
+func validateCatalogEntry(entry *pb.CatalogEntry, imported map[string]bool) error {
+	laptop := entry.GetLaptop()
+	if laptop == nil {
+		return status.Errorf(codes.InvalidArgument, "catalog entry has no laptop")
+	}
+
+	_, err := uuid.Parse(laptop.GetId())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
+	}
+
+	err = validation.ValidateLaptop(laptop)
+	if err != nil {
+		return invalidLaptopError(err)
+	}
+
+	if imported[laptop.GetId()] {
+		return status.Errorf(codes.InvalidArgument, "laptop %s is imported twice", laptop.GetId())
+	}
+	imported[laptop.GetId()] = true
+
+	for _, image := range entry.GetImages() {
+		// the ids are generated by the image store, so a valid one can't name another file
+		_, err := uuid.Parse(image.GetId())
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "an image ID of laptop %s is not a valid UUID: %v", laptop.GetId(), err)
+		}
+
+		if imported[image.GetId()] {
+			return status.Errorf(codes.InvalidArgument, "image %s is imported twice", image.GetId())
+		}
+		imported[image.GetId()] = true
+	}
+
+	return nil
+}
+
+// importCatalogImages records the images of every entry, replacing the stored images with the same id
+func (server *LaptopServer) importCatalogImages(entries []*pb.CatalogEntry) error {
+	if server.ImageStore == nil {
+		return nil
+	}
+
+	images := []*ImageInfo{}
+	for _, entry := range entries {
+		for _, image := range entry.GetImages() {
+			images = append(images, &ImageInfo{
+				ID:       image.GetId(),
+				LaptopID: entry.GetLaptop().GetId(),
+				Type:     image.GetImageType(),
+				Path:     image.GetPath(),
+			})
+		}
+	}
+
+	err := server.ImageStore.Import(images...)
+	if errors.Is(err, InvalidImageException) {
+		return status.Errorf(codes.InvalidArgument, "cannot import images: %v", err)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "cannot import images: %v", err)
+	}
+
+	return nil
+}
+
+// importCatalogLaptop saves a laptop, replacing the stored laptop with the same id even if it is in the trash
+func (server *LaptopServer) importCatalogLaptop(laptop *pb.Laptop) error {
+	laptop.DeletedAt = nil
+
+	err := server.LaptopStore.Save(laptop)
+	if errors.Is(err, DuplicateException) {
+		_, err = server.LaptopStore.Restore(laptop.GetId(), 0)
+		if err != nil && !errors.Is(err, NotFoundException) {
+			return status.Errorf(codes.Internal, "cannot restore laptop %s: %v", laptop.GetId(), err)
+		}
+
+		// an empty mask replaces every field
+		_, err = server.LaptopStore.Update(laptop, nil, 0)
+	}
+
+	if err != nil {
+		return status.Errorf(codes.Internal, "cannot import laptop %s: %v", laptop.GetId(), err)
+	}
+
+	return nil
+}
+
+// removeOtherLaptops removes the laptops and images missing from the imported entries, the trash included,
+// and returns how many laptops were removed. Image files the entries refer to are kept.
+func (server *LaptopServer) removeOtherLaptops(ctx context.Context, entries []*pb.CatalogEntry) (int, error) {
+	imported := make(map[string]bool)
+	imageIDs := []string{}
+	for _, entry := range entries {
+		imported[entry.GetLaptop().GetId()] = true
+		for _, image := range entry.GetImages() {
+			imageIDs = append(imageIDs, image.GetId())
+		}
+	}
+
+	// the images of the removed laptops go first, so the purge below finds none of them
+	if server.ImageStore != nil {
+		err := server.ImageStore.DeleteAllExcept(imageIDs)
+		if err != nil {
+			return 0, status.Errorf(codes.Internal, "cannot delete images: %v", err)
+		}
+	}
+
+	ids := []string{}
+	err := server.LaptopStore.Search(ctx, nil, func(laptop *pb.Laptop) error {
+		if !imported[laptop.GetId()] {
+			ids = append(ids, laptop.GetId())
+		}
+		return nil
+	})
+
+	if err != nil {
+		if err := contextError(ctx); err != nil {
+			return 0, err
+		}
+		return 0, status.Errorf(codes.Internal, "cannot search laptops: %v", err)
+	}
+
+	for _, id := range ids {
+		err := server.LaptopStore.Delete(id, 0)
+		if err != nil && !errors.Is(err, NotFoundException) {
+			return 0, status.Errorf(codes.Internal, "cannot delete laptop %s: %v", id, err)
+		}
+	}
+
+	// every laptop in the trash was deleted before the end of time, the imported ones were restored
+	purged, err := server.purgeLaptops(time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return 0, status.Errorf(codes.Internal, "%v", err)
+	}
+
+	return len(purged), nil
 }
 
 func logError(err error) error {
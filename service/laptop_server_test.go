@@ -1,13 +1,25 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"github.com/Adetunjii/go-grpc/pb"
 	"github.com/Adetunjii/go-grpc/sample"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"os"
+	"sort"
 	"testing"
+	"time"
 )
 
 func TestLaptopServer_CreateLaptop(t *testing.T) {
@@ -19,6 +31,9 @@ func TestLaptopServer_CreateLaptop(t *testing.T) {
 	laptopInvalidId := sample.NewLaptop()
 	laptopInvalidId.Id = "invalid-uuid"
 
+	laptopInvalidCPU := sample.NewLaptop()
+	laptopInvalidCPU.Cpu.MaxGhz = laptopInvalidCPU.Cpu.MinGhz - 1
+
 	laptopDuplicateId := sample.NewLaptop()
 	storeWithExistingLaptop := NewInMemoryLaptopStore()
 	err := storeWithExistingLaptop.Save(laptopDuplicateId)
@@ -48,6 +63,12 @@ func TestLaptopServer_CreateLaptop(t *testing.T) {
 			store:  NewInMemoryLaptopStore(),
 			code:   codes.InvalidArgument,
 		},
+		{
+			name:   "failure_invalid_laptop",
+			laptop: laptopInvalidCPU,
+			store:  NewInMemoryLaptopStore(),
+			code:   codes.InvalidArgument,
+		},
 		{
 			name:   "failure_duplicate_laptop",
 			laptop: laptopDuplicateId,
@@ -57,6 +78,7 @@ func TestLaptopServer_CreateLaptop(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
+		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
@@ -64,7 +86,7 @@ func TestLaptopServer_CreateLaptop(t *testing.T) {
 				Laptop: tc.laptop,
 			}
 
-			server := NewLaptopServer(tc.store, nil)
+			server := NewLaptopServer(tc.store, nil, nil, nil)
 			res, err := server.CreateLaptop(context.Background(), req)
 			if tc.code == codes.OK {
 				require.NoError(t, err)
@@ -84,3 +106,775 @@ func TestLaptopServer_CreateLaptop(t *testing.T) {
 		})
 	}
 }
+
+func TestLaptopServer_CreateLaptopFieldViolations(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	laptop.PriceUsd = -1
+	laptop.Screen.Resolution.Width = 0
+
+	server := NewLaptopServer(NewInMemoryLaptopStore(), nil, nil, nil)
+	res, err := server.CreateLaptop(context.Background(), &pb.CreatelaptopRequest{Laptop: laptop})
+	require.Error(t, err)
+	require.Nil(t, res)
+
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+
+	fields := []string{}
+	for _, violation := range badRequest.GetFieldViolations() {
+		fields = append(fields, violation.GetField())
+	}
+	require.Equal(t, []string{"price_usd", "screen.resolution.width"}, fields)
+}
+
+func TestLaptopServer_GetLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	store := NewInMemoryLaptopStore()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		id   string
+		code codes.Code
+	}{
+		{
+			name: "success",
+			id:   laptop.Id,
+			code: codes.OK,
+		},
+		{
+			name: "failure_invalid_id",
+			id:   "invalid-uuid",
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "failure_not_found",
+			id:   sample.NewLaptop().Id,
+			code: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := &pb.GetLaptopRequest{
+				Id: tc.id,
+			}
+
+			server := NewLaptopServer(store, nil, nil, nil)
+			res, err := server.GetLaptop(context.Background(), req)
+			if tc.code == codes.OK {
+				require.NoError(t, err)
+				require.NotNil(t, res)
+				require.Equal(t, tc.id, res.GetLaptop().GetId())
+			} else {
+				require.Error(t, err)
+				require.Nil(t, res)
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, tc.code, st.Code())
+			}
+		})
+	}
+}
+
+func TestLaptopServer_UpdateLaptop(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		paths   []string
+		exists  bool
+		version uint64
+		code    codes.Code
+	}{
+		{
+			name:   "success_top_level_field",
+			paths:  []string{"price_usd"},
+			exists: true,
+			code:   codes.OK,
+		},
+		{
+			name:   "success_nested_field",
+			paths:  []string{"cpu.max_ghz"},
+			exists: true,
+			code:   codes.OK,
+		},
+		{
+			name:   "failure_unknown_path",
+			paths:  []string{"cpu.turbo"},
+			exists: true,
+			code:   codes.InvalidArgument,
+		},
+		{
+			name:   "failure_immutable_path",
+			paths:  []string{"id"},
+			exists: true,
+			code:   codes.InvalidArgument,
+		},
+		{
+			name:   "failure_not_found",
+			paths:  []string{"price_usd"},
+			exists: false,
+			code:   codes.NotFound,
+		},
+		{
+			name:    "success_expected_version",
+			paths:   []string{"price_usd"},
+			exists:  true,
+			version: 1,
+			code:    codes.OK,
+		},
+		{
+			name:    "failure_version_mismatch",
+			paths:   []string{"price_usd"},
+			exists:  true,
+			version: 2,
+			code:    codes.Aborted,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			original := sample.NewLaptop()
+			store := NewInMemoryLaptopStore()
+			if tc.exists {
+				require.NoError(t, store.Save(original))
+			}
+
+			changes := sample.NewLaptop()
+			changes.Id = original.Id
+			changes.PriceUsd = original.PriceUsd + 100
+			changes.Cpu.MaxGhz = original.Cpu.MaxGhz + 1
+
+			req := &pb.UpdateLaptopRequest{
+				Laptop:          changes,
+				UpdateMask:      &fieldmaskpb.FieldMask{Paths: tc.paths},
+				ExpectedVersion: tc.version,
+			}
+
+			server := NewLaptopServer(store, nil, nil, nil)
+			res, err := server.UpdateLaptop(context.Background(), req)
+			if tc.code != codes.OK {
+				require.Error(t, err)
+				require.Nil(t, res)
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, tc.code, st.Code())
+				return
+			}
+
+			require.NoError(t, err)
+			updated := res.GetLaptop()
+			require.EqualValues(t, 2, updated.GetVersion())
+			require.True(t, updated.GetUpdatedAt().AsTime().After(original.GetUpdatedAt().AsTime()))
+
+			switch tc.paths[0] {
+			case "price_usd":
+				require.Equal(t, changes.PriceUsd, updated.PriceUsd)
+				require.Equal(t, original.Cpu.MaxGhz, updated.Cpu.MaxGhz)
+			case "cpu.max_ghz":
+				require.Equal(t, changes.Cpu.MaxGhz, updated.Cpu.MaxGhz)
+				require.Equal(t, original.Cpu.Name, updated.Cpu.Name)
+				require.Equal(t, original.PriceUsd, updated.PriceUsd)
+			}
+			require.Equal(t, original.Brand, updated.Brand)
+		})
+	}
+}
+
+func TestLaptopServer_UpdateLaptopFieldViolations(t *testing.T) {
+	t.Parallel()
+
+	original := sample.NewLaptop()
+	store := NewInMemoryLaptopStore()
+	require.NoError(t, store.Save(original))
+
+	changes := &pb.Laptop{Id: original.Id, PriceUsd: -1, Screen: &pb.Screen{}}
+	req := &pb.UpdateLaptopRequest{
+		Laptop:     changes,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"price_usd", "screen.resolution"}},
+	}
+
+	server := NewLaptopServer(store, nil, nil, nil)
+	res, err := server.UpdateLaptop(context.Background(), req)
+	require.Error(t, err)
+	require.Nil(t, res)
+
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+
+	fields := []string{}
+	for _, violation := range badRequest.GetFieldViolations() {
+		fields = append(fields, violation.GetField())
+	}
+	require.Equal(t, []string{"price_usd", "screen.resolution"}, fields)
+
+	// the invalid update isn't applied
+	stored, err := store.FindById(original.Id)
+	require.NoError(t, err)
+	require.Equal(t, original.PriceUsd, stored.GetPriceUsd())
+	require.EqualValues(t, 1, stored.GetVersion())
+}
+
+// racingLaptopStore changes the laptop right before the first update, as another client would
+type racingLaptopStore struct {
+	*InMemoryLaptopStore
+	race func()
+}
+
+func (store *racingLaptopStore) Update(laptop *pb.Laptop, mask *fieldmaskpb.FieldMask, expectedVersion uint64) (*pb.Laptop, error) {
+	if store.race != nil {
+		store.race()
+		store.race = nil
+	}
+	return store.InMemoryLaptopStore.Update(laptop, mask, expectedVersion)
+}
+
+func TestLaptopServer_UpdateLaptopRechecksConcurrentChange(t *testing.T) {
+	t.Parallel()
+
+	original := sample.NewLaptop()
+	store := &racingLaptopStore{InMemoryLaptopStore: NewInMemoryLaptopStore()}
+	require.NoError(t, store.Save(original))
+
+	// each change is valid on the original laptop, but not both together
+	store.race = func() {
+		changes := &pb.Laptop{Id: original.Id, Cpu: &pb.CPU{MaxGhz: original.Cpu.MinGhz}}
+		_, err := store.InMemoryLaptopStore.Update(changes, &fieldmaskpb.FieldMask{Paths: []string{"cpu.max_ghz"}}, 0)
+		require.NoError(t, err)
+	}
+
+	req := &pb.UpdateLaptopRequest{
+		Laptop:     &pb.Laptop{Id: original.Id, Cpu: &pb.CPU{MinGhz: original.Cpu.MaxGhz}},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"cpu.min_ghz"}},
+	}
+
+	server := NewLaptopServer(store, nil, nil, nil)
+	_, err := server.UpdateLaptop(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stored, err := store.FindById(original.Id)
+	require.NoError(t, err)
+	require.Equal(t, original.Cpu.MinGhz, stored.GetCpu().GetMinGhz())
+	require.EqualValues(t, 2, stored.GetVersion())
+}
+
+func TestLaptopServer_DeleteLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	server := NewLaptopServer(laptopStore, nil, nil, nil)
+	res, err := server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.Id})
+	require.NoError(t, err)
+	require.NotNil(t, res)
+
+	deleted, err := laptopStore.FindById(laptop.Id)
+	require.NoError(t, err)
+	require.Nil(t, deleted)
+
+	found := 0
+	err = laptopStore.Search(context.Background(), &pb.Filter{MaxPriceUsd: 1e6}, func(laptop *pb.Laptop) error {
+		found++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Zero(t, found)
+
+	trash := []*pb.Laptop{}
+	err = laptopStore.ListDeleted(context.Background(), func(laptop *pb.Laptop) error {
+		trash = append(trash, laptop)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	require.Equal(t, laptop.Id, trash[0].Id)
+	require.NotNil(t, trash[0].DeletedAt)
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.Id})
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.NotFound, st.Code())
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: "invalid-uuid"})
+	st, ok = status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+
+	_, err = server.RestoreLaptop(context.Background(), &pb.RestoreLaptopRequest{Id: laptop.Id, ExpectedVersion: trash[0].Version - 1})
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	restored, err := server.RestoreLaptop(context.Background(), &pb.RestoreLaptopRequest{Id: laptop.Id, ExpectedVersion: trash[0].Version})
+	require.NoError(t, err)
+	require.Nil(t, restored.GetLaptop().GetDeletedAt())
+	require.Equal(t, trash[0].Version+1, restored.GetLaptop().GetVersion())
+
+	other, err := laptopStore.FindById(laptop.Id)
+	require.NoError(t, err)
+	require.NotNil(t, other)
+
+	_, err = server.RestoreLaptop(context.Background(), &pb.RestoreLaptopRequest{Id: laptop.Id})
+	st, ok = status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.NotFound, st.Code())
+}
+
+func TestLaptopServer_PurgeDeletedLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	imageStore := NewDiskImageStore(t.TempDir())
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	other := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(other))
+
+	imageID, err := imageStore.Save(laptop.Id, ".jpg", *bytes.NewBufferString("image"))
+	require.NoError(t, err)
+	otherImageID, err := imageStore.Save(other.Id, ".jpg", *bytes.NewBufferString("image"))
+	require.NoError(t, err)
+
+	imagePath := imageStore.images[imageID].Path
+	otherImagePath := imageStore.images[otherImageID].Path
+
+	server := NewLaptopServer(laptopStore, imageStore, nil, nil)
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.Id})
+	require.NoError(t, err)
+
+	// images stay around while the laptop can still be restored
+	require.FileExists(t, imagePath)
+
+	purged, err := server.PurgeDeletedLaptops(time.Hour)
+	require.NoError(t, err)
+	require.Empty(t, purged)
+
+	purged, err = server.PurgeDeletedLaptops(0)
+	require.NoError(t, err)
+	require.Equal(t, []string{laptop.Id}, purged)
+
+	_, err = os.Stat(imagePath)
+	require.True(t, os.IsNotExist(err))
+	require.NotContains(t, imageStore.images, imageID)
+
+	require.FileExists(t, otherImagePath)
+	require.Contains(t, imageStore.images, otherImageID)
+
+	_, err = server.RestoreLaptop(context.Background(), &pb.RestoreLaptopRequest{Id: laptop.Id})
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.NotFound, st.Code())
+}
+
+// failingImageStore fails to delete the images of one laptop
+type failingImageStore struct {
+	*DiskImageStore
+	failingID string
+}
+
+func (store *failingImageStore) DeleteByLaptopID(laptopID string) error {
+	if laptopID == store.failingID {
+		return errors.New("disk failure")
+	}
+	return store.DiskImageStore.DeleteByLaptopID(laptopID)
+}
+
+func TestLaptopServer_PurgeDeletedLaptopsImageFailure(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop(), sample.NewLaptop()}
+	imageStore := &failingImageStore{DiskImageStore: NewDiskImageStore(t.TempDir()), failingID: laptops[0].Id}
+
+	for _, laptop := range laptops {
+		require.NoError(t, laptopStore.Save(laptop))
+		_, err := imageStore.Save(laptop.Id, ".jpg", *bytes.NewBufferString("image"))
+		require.NoError(t, err)
+		require.NoError(t, laptopStore.Delete(laptop.Id, 0))
+	}
+
+	server := NewLaptopServer(laptopStore, imageStore, nil, nil)
+	purged, err := server.PurgeDeletedLaptops(0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), laptops[0].Id)
+	require.Len(t, purged, len(laptops))
+
+	// a failure doesn't stop the images of the other purged laptops from being deleted
+	for _, laptop := range laptops[1:] {
+		images, err := imageStore.FindByLaptopID(laptop.Id)
+		require.NoError(t, err)
+		require.Empty(t, images)
+	}
+}
+
+func TestLaptopServer_CreateLaptopIgnoresDeletedAt(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	laptop.DeletedAt = timestamppb.Now()
+
+	server := NewLaptopServer(NewInMemoryLaptopStore(), nil, nil, nil)
+	_, err := server.CreateLaptop(context.Background(), &pb.CreatelaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	res, err := server.GetLaptop(context.Background(), &pb.GetLaptopRequest{Id: laptop.Id})
+	require.NoError(t, err)
+	require.Nil(t, res.GetLaptop().GetDeletedAt())
+}
+
+func TestLaptopServer_SearchFacets(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+
+	newLaptop := func(brand string, ram *pb.Memory, year uint32, price float64) *pb.Laptop {
+		laptop := sample.NewLaptop()
+		laptop.Brand = brand
+		laptop.Ram = ram
+		laptop.ReleaseYear = year
+		laptop.PriceUsd = price
+		laptop.Storages = []*pb.Storage{sample.NewSSD(), sample.NewSSD()}
+		return laptop
+	}
+
+	laptops := []*pb.Laptop{
+		newLaptop("Dell", &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}, 2019, 1200),
+		newLaptop("Apple", &pb.Memory{Value: 16384, Unit: pb.Memory_MEGABYTE}, 2018, 2200),
+		newLaptop("Dell", &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}, 2019, 499),
+		newLaptop("Apple", &pb.Memory{Value: 32, Unit: pb.Memory_GIGABYTE}, 2019, 3500),
+		newLaptop("Dell", &pb.Memory{Value: 32, Unit: pb.Memory_GIGABYTE}, 2017, 2100),
+	}
+
+	for _, laptop := range laptops {
+		require.NoError(t, laptopStore.Save(laptop))
+	}
+	require.NoError(t, laptopStore.Delete(laptops[4].Id, 0))
+
+	server := NewLaptopServer(laptopStore, nil, nil, nil)
+
+	buckets := func(res *pb.SearchFacetsResponse, name string) map[string]uint32 {
+		for _, facet := range res.GetFacets() {
+			if facet.GetName() == name {
+				counts := make(map[string]uint32)
+				for _, bucket := range facet.GetBuckets() {
+					counts[bucket.GetValue()] = bucket.GetCount()
+				}
+				return counts
+			}
+		}
+
+		require.Failf(t, "missing facet", "%s", name)
+		return nil
+	}
+
+	res, err := server.SearchFacets(context.Background(), &pb.SearchFacetsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, uint32(4), res.GetTotalCount())
+	require.Len(t, res.GetFacets(), len(facetFields))
+
+	require.Equal(t, map[string]uint32{"Dell": 2, "Apple": 2}, buckets(res, "brand"))
+	require.Equal(t, map[string]uint32{"8 GB": 1, "16 GB": 2, "32 GB": 1}, buckets(res, "ram"))
+	require.Equal(t, map[string]uint32{"SSD": 4}, buckets(res, "storages.driver"))
+	require.Equal(t, map[string]uint32{"2018": 1, "2019": 3}, buckets(res, "release_year"))
+	require.Equal(t, map[string]uint32{"0-500": 1, "1000-1500": 1, "2000-2500": 1, "3000+": 1}, buckets(res, "price_usd"))
+
+	// ranged facets follow their natural order
+	ram := res.GetFacets()[2]
+	require.Equal(t, "ram", ram.GetName())
+	require.Equal(t, "8 GB", ram.GetBuckets()[0].GetValue())
+	require.Equal(t, "32 GB", ram.GetBuckets()[2].GetValue())
+
+	res, err = server.SearchFacets(context.Background(), &pb.SearchFacetsRequest{
+		Filter: &pb.Filter{MinRam: &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}, MaxPriceUsd: 3000},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), res.GetTotalCount())
+	require.Equal(t, map[string]uint32{"Dell": 1, "Apple": 1}, buckets(res, "brand"))
+	require.Equal(t, map[string]uint32{"16 GB": 2}, buckets(res, "ram"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = server.SearchFacets(ctx, &pb.SearchFacetsRequest{})
+	require.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestLaptopServer_ExplainSearch(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+
+	newLaptop := func(brand string, name string, price float64, ram uint64) *pb.Laptop {
+		laptop := sample.NewLaptop()
+		laptop.Brand = brand
+		laptop.Name = name
+		laptop.PriceUsd = price
+		laptop.Ram = &pb.Memory{Value: ram, Unit: pb.Memory_GIGABYTE}
+		return laptop
+	}
+
+	laptops := []*pb.Laptop{
+		newLaptop("Dell", "Macbook Pro", 1200, 16),
+		newLaptop("Dell", "Macbook Pro", 2500, 16),
+		newLaptop("Apple", "Macbook Pro", 1000, 16),
+		newLaptop("Apple", "Macbook Pro", 2500, 8),
+		newLaptop("Dell", "XPS 13", 1800, 8),
+	}
+
+	for _, laptop := range laptops {
+		require.NoError(t, laptopStore.Save(laptop))
+	}
+
+	server := NewLaptopServer(laptopStore, nil, nil, nil)
+
+	rejections := func(explanation *pb.SearchExplanation) []string {
+		counts := []string{}
+		for _, rejection := range explanation.GetRejections() {
+			counts = append(counts, fmt.Sprintf("%s=%d", rejection.GetCriterion(), rejection.GetRejectedCount()))
+		}
+		return counts
+	}
+
+	nearMisses := func(explanation *pb.SearchExplanation) map[string]string {
+		misses := make(map[string]string)
+		for _, miss := range explanation.GetNearMisses() {
+			misses[miss.GetLaptop().GetId()] = miss.GetFailedCriterion()
+		}
+		return misses
+	}
+
+	filter := &pb.Filter{
+		MaxPriceUsd: 2000,
+		MinRam:      &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE},
+		Brands:      []string{"Dell"},
+	}
+
+	res, err := server.ExplainSearch(context.Background(), &pb.ExplainSearchRequest{Filter: filter})
+	require.NoError(t, err)
+
+	explanation := res.GetExplanation()
+	require.Equal(t, uint32(5), explanation.GetScannedCount())
+	require.Equal(t, uint32(1), explanation.GetMatchedCount())
+	require.Equal(t, []string{"max_price_usd=2", "min_ram=2", "brands=2"}, rejections(explanation))
+	require.Equal(t, map[string]string{
+		laptops[1].Id: "max_price_usd",
+		laptops[2].Id: "brands",
+		laptops[4].Id: "min_ram",
+	}, nearMisses(explanation))
+
+	// the near misses with the smallest ids are kept, whatever order the store visits laptops in
+	missIDs := []string{laptops[1].Id, laptops[2].Id, laptops[4].Id}
+	sort.Strings(missIDs)
+
+	res, err = server.ExplainSearch(context.Background(), &pb.ExplainSearchRequest{Filter: filter, MaxNearMisses: 2})
+	require.NoError(t, err)
+	keptIDs := []string{}
+	for _, miss := range res.GetExplanation().GetNearMisses() {
+		keptIDs = append(keptIDs, miss.GetLaptop().GetId())
+	}
+	require.Equal(t, missIDs[:2], keptIDs)
+
+	res, err = server.ExplainSearch(context.Background(), &pb.ExplainSearchRequest{
+		FilterExpression: `price_usd < 2000`,
+		Query:            "xps",
+	})
+	require.NoError(t, err)
+
+	explanation = res.GetExplanation()
+	require.Equal(t, uint32(1), explanation.GetMatchedCount())
+	require.Equal(t, []string{"filter_expression=2", "query=4"}, rejections(explanation))
+	require.Equal(t, map[string]string{
+		laptops[0].Id: "query",
+		laptops[2].Id: "query",
+	}, nearMisses(explanation))
+
+	_, err = server.ExplainSearch(context.Background(), &pb.ExplainSearchRequest{
+		Filter:           filter,
+		FilterExpression: `price_usd < 2000`,
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = server.ExplainSearch(ctx, &pb.ExplainSearchRequest{})
+	require.Equal(t, codes.Canceled, status.Code(err))
+}
+
+// stalledSearchStream blocks every Send until released, like a client that stopped reading
+type stalledSearchStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	stalled chan struct{}
+	release chan struct{}
+	sent    int
+}
+
+func (stream *stalledSearchStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *stalledSearchStream) Send(res *pb.SearchLaptopResponse) error {
+	if stream.sent == 0 {
+		close(stream.stalled)
+		<-stream.release
+	}
+
+	stream.sent++
+	return nil
+}
+
+func TestLaptopServer_StalledSearchStreamDoesNotBlockCreate(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, laptopStore.Save(sample.NewLaptop()))
+	}
+
+	server := NewLaptopServer(laptopStore, nil, nil, nil)
+	stream := &stalledSearchStream{
+		ctx:     context.Background(),
+		stalled: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	searched := make(chan error)
+	go func() {
+		searched <- server.SearchLaptop(&pb.SearchLaptopRequest{}, stream)
+	}()
+
+	<-stream.stalled
+
+	created := make(chan error)
+	go func() {
+		_, err := server.CreateLaptop(context.Background(), &pb.CreatelaptopRequest{Laptop: sample.NewLaptop()})
+		created <- err
+	}()
+
+	select {
+	case err := <-created:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "CreateLaptop is blocked by a stalled search stream")
+	}
+
+	close(stream.release)
+	require.NoError(t, <-searched)
+	require.Equal(t, 5, stream.sent)
+}
+
+func TestLaptopServer_FindSimilarLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	base := sample.NewLaptop()
+
+	newLaptop := func(price float64, ramGB uint64) *pb.Laptop {
+		laptop := proto.Clone(base).(*pb.Laptop)
+		laptop.Id = uuid.New().String()
+		laptop.PriceUsd = price
+		laptop.Ram = &pb.Memory{Value: ramGB, Unit: pb.Memory_GIGABYTE}
+		require.NoError(t, laptopStore.Save(laptop))
+		return laptop
+	}
+
+	target := newLaptop(1000, 16)
+	slightlyPricier := newLaptop(1100, 16)
+	moreRam := newLaptop(1000, 32)
+	muchPricier := newLaptop(3000, 16)
+
+	deleted := newLaptop(1000, 16)
+	require.NoError(t, laptopStore.Delete(deleted.Id, 0))
+
+	server := NewLaptopServer(laptopStore, nil, nil, nil)
+
+	testCases := []struct {
+		name     string
+		req      *pb.FindSimilarLaptopsRequest
+		expected []string
+		code     codes.Code
+	}{
+		{
+			name:     "default_weights",
+			req:      &pb.FindSimilarLaptopsRequest{LaptopId: target.Id, K: 1},
+			expected: []string{slightlyPricier.Id},
+		},
+		{
+			name:     "ram_ignored",
+			req:      &pb.FindSimilarLaptopsRequest{LaptopId: target.Id, K: 2, Weights: map[string]float64{"ram": 0}},
+			expected: []string{moreRam.Id, slightlyPricier.Id},
+		},
+		{
+			name:     "price_matters_most",
+			req:      &pb.FindSimilarLaptopsRequest{LaptopId: target.Id, Weights: map[string]float64{"price_usd": 1000}},
+			expected: []string{moreRam.Id, slightlyPricier.Id, muchPricier.Id},
+		},
+		{
+			name: "unknown_feature",
+			req:  &pb.FindSimilarLaptopsRequest{LaptopId: target.Id, Weights: map[string]float64{"color": 1}},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "negative_weight",
+			req:  &pb.FindSimilarLaptopsRequest{LaptopId: target.Id, Weights: map[string]float64{"ram": -1}},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "invalid_id",
+			req:  &pb.FindSimilarLaptopsRequest{LaptopId: "invalid"},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "deleted_laptop",
+			req:  &pb.FindSimilarLaptopsRequest{LaptopId: deleted.Id},
+			code: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := server.FindSimilarLaptops(context.Background(), tc.req)
+			if tc.code != codes.OK {
+				require.Equal(t, tc.code, status.Code(err))
+				return
+			}
+
+			require.NoError(t, err)
+
+			ids := []string{}
+			for i, similar := range res.GetSimilarLaptops() {
+				ids = append(ids, similar.GetLaptop().GetId())
+				if i > 0 {
+					require.GreaterOrEqual(t, similar.GetDistance(), res.GetSimilarLaptops()[i-1].GetDistance())
+				}
+			}
+			require.Equal(t, tc.expected, ids)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"fmt"
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/units"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// similarityFeature is one dimension of the vector used to compare laptops
+type similarityFeature struct {
+	name  string
+	value func(laptop *pb.Laptop) float64
+}
+
+var similarityFeatures = []similarityFeature{
+	{
+		name: "cpu.number_of_cores",
+		value: func(laptop *pb.Laptop) float64 {
+			return float64(laptop.GetCpu().GetNumberOfCores())
+		},
+	},
+	{
+		name: "cpu.number_of_threads",
+		value: func(laptop *pb.Laptop) float64 {
+			return float64(laptop.GetCpu().GetNumberOfThreads())
+		},
+	},
+	{
+		name: "cpu.min_ghz",
+		value: func(laptop *pb.Laptop) float64 {
+			return laptop.GetCpu().GetMinGhz()
+		},
+	},
+	{
+		name: "ram",
+		value: func(laptop *pb.Laptop) float64 {
+			return float64(units.MemoryBits(laptop.GetRam()))
+		},
+	},
+	{
+		name: "storages",
+		value: func(laptop *pb.Laptop) float64 {
+			total := uint64(0)
+			for _, storage := range laptop.GetStorages() {
+				total += units.MemoryBits(storage.GetMemory())
+			}
+			return float64(total)
+		},
+	},
+	{
+		name: "gpus.memory",
+		value: func(laptop *pb.Laptop) float64 {
+			return float64(maxGPUMemoryBits(laptop))
+		},
+	},
+	{
+		name: "screen.size_inch",
+		value: func(laptop *pb.Laptop) float64 {
+			return float64(laptop.GetScreen().GetSizeInch())
+		},
+	},
+	{
+		name: "price_usd",
+		value: func(laptop *pb.Laptop) float64 {
+			return laptop.GetPriceUsd()
+		},
+	},
+	{
+		name: "weight_kg",
+		value: func(laptop *pb.Laptop) float64 {
+			weight, _ := units.WeightKg(laptop)
+			return weight
+		},
+	},
+}
+
+// SimilarityWeights gives the importance of each feature when comparing laptops, by feature name.
+// Features are normalized to [0, 1] over the catalog, so weights are comparable with each other.
+type SimilarityWeights map[string]float64
+
+func DefaultSimilarityWeights() SimilarityWeights {
+	weights := make(SimilarityWeights)
+	for _, feature := range similarityFeatures {
+		weights[feature.name] = 1
+	}
+
+	return weights
+}
+
+// ParseSimilarityWeights reads weights written as "price_usd=2,ram=0.5",
+// the features left out keep their default weight
+func ParseSimilarityWeights(text string) (SimilarityWeights, error) {
+	weights := DefaultSimilarityWeights()
+
+	for _, pair := range strings.Split(text, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid weight %q, expected feature=weight", pair)
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight %q: %w", pair, err)
+		}
+
+		weights[strings.TrimSpace(parts[0])] = weight
+	}
+
+	return weights, weights.validate()
+}
+
+// merge returns a copy of the weights with the given ones replaced
+func (weights SimilarityWeights) merge(overrides map[string]float64) (SimilarityWeights, error) {
+	merged := make(SimilarityWeights)
+	for name, weight := range weights {
+		merged[name] = weight
+	}
+	for name, weight := range overrides {
+		merged[name] = weight
+	}
+
+	return merged, merged.validate()
+}
+
+func (weights SimilarityWeights) validate() error {
+	known := make(map[string]bool)
+	for _, feature := range similarityFeatures {
+		known[feature.name] = true
+	}
+
+	for name, weight := range weights {
+		if !known[name] {
+			return fmt.Errorf("unknown feature %q", name)
+		}
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return fmt.Errorf("weight of %q must be a finite number that is not negative", name)
+		}
+	}
+
+	return nil
+}
+
+// featureVector returns the raw value of every feature of the laptop, in similarityFeatures order
+func featureVector(laptop *pb.Laptop) []float64 {
+	vector := make([]float64, len(similarityFeatures))
+	for i, feature := range similarityFeatures {
+		vector[i] = feature.value(laptop)
+		if math.IsNaN(vector[i]) || math.IsInf(vector[i], 0) {
+			vector[i] = 0
+		}
+	}
+
+	return vector
+}
+
+type similarityCandidate struct {
+	laptop   *pb.Laptop
+	vector   []float64
+	distance float64
+}
+
+// nearestLaptops returns the k candidates closest to the target by weighted euclidean distance,
+// closest first. Every feature is first scaled to [0, 1] with the minimum and maximum over the candidates and the target.
+func nearestLaptops(target []float64, candidates []*similarityCandidate, weights SimilarityWeights, k int) []*similarityCandidate {
+	min := append([]float64{}, target...)
+	max := append([]float64{}, target...)
+	for _, candidate := range candidates {
+		for i, value := range candidate.vector {
+			min[i] = math.Min(min[i], value)
+			max[i] = math.Max(max[i], value)
+		}
+	}
+
+	for _, candidate := range candidates {
+		sum := 0.0
+		for i, feature := range similarityFeatures {
+			if max[i] == min[i] {
+				continue
+			}
+
+			delta := (candidate.vector[i] - target[i]) / (max[i] - min[i])
+			sum += weights[feature.name] * delta * delta
+		}
+
+		candidate.distance = math.Sqrt(sum)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].laptop.GetId() < candidates[j].laptop.GetId()
+	})
+
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	return candidates
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"container/heap"
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/units"
+	"sort"
+)
+
+// laptopLess reports whether laptop a is ranked before laptop b
+type laptopLess func(a *pb.Laptop, b *pb.Laptop) bool
+
+// laptopOrder returns the ranking for the sort key and direction,
+// ties are broken by id so that the order is deterministic
+func laptopOrder(key pb.SearchLaptopRequest_SortKey, direction pb.SearchLaptopRequest_SortDirection) laptopLess {
+	var compare func(a *pb.Laptop, b *pb.Laptop) int
+
+	switch key {
+	case pb.SearchLaptopRequest_PRICE:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int {
+			return compareFloat(a.GetPriceUsd(), b.GetPriceUsd())
+		}
+	case pb.SearchLaptopRequest_RELEASE_YEAR:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int {
+			return compareUint(uint64(a.GetReleaseYear()), uint64(b.GetReleaseYear()))
+		}
+	case pb.SearchLaptopRequest_CPU_CORES:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int {
+			return compareUint(uint64(a.GetCpu().GetNumberOfCores()), uint64(b.GetCpu().GetNumberOfCores()))
+		}
+	case pb.SearchLaptopRequest_RAM:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int {
+			return compareUint(units.MemoryBits(a.GetRam()), units.MemoryBits(b.GetRam()))
+		}
+	case pb.SearchLaptopRequest_UPDATED_AT:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int {
+			ta, tb := a.GetUpdatedAt().AsTime(), b.GetUpdatedAt().AsTime()
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			default:
+				return 0
+			}
+		}
+	default:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int { return 0 }
+	}
+
+	if direction == pb.SearchLaptopRequest_DESCENDING {
+		ascending := compare
+		compare = func(a *pb.Laptop, b *pb.Laptop) int { return -ascending(a, b) }
+	}
+
+	return func(a *pb.Laptop, b *pb.Laptop) bool {
+		if c := compare(a, b); c != 0 {
+			return c < 0
+		}
+		return a.GetId() < b.GetId()
+	}
+}
+
+func compareFloat(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareUint(a uint64, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// topLaptops keeps the best ranked laptops seen so far in a bounded heap,
+// the worst of them sits at the root so it can be replaced in O(log n)
+type topLaptops struct {
+	less    laptopLess
+	limit   int // 0 keeps every laptop
+	laptops []*pb.Laptop
+}
+
+func newTopLaptops(less laptopLess, limit int) *topLaptops {
+	return &topLaptops{less: less, limit: limit}
+}
+
+func (top *topLaptops) Len() int {
+	return len(top.laptops)
+}
+
+func (top *topLaptops) Less(i, j int) bool {
+	return top.less(top.laptops[j], top.laptops[i])
+}
+
+func (top *topLaptops) Swap(i, j int) {
+	top.laptops[i], top.laptops[j] = top.laptops[j], top.laptops[i]
+}
+
+func (top *topLaptops) Push(x interface{}) {
+	top.laptops = append(top.laptops, x.(*pb.Laptop))
+}
+
+func (top *topLaptops) Pop() interface{} {
+	n := len(top.laptops)
+	laptop := top.laptops[n-1]
+	top.laptops = top.laptops[:n-1]
+	return laptop
+}
+
+// add offers a laptop to the heap, it is dropped if the heap is full of better ranked laptops
+func (top *topLaptops) add(laptop *pb.Laptop) {
+	if top.limit == 0 || top.Len() < top.limit {
+		heap.Push(top, laptop)
+		return
+	}
+
+	if top.less(laptop, top.laptops[0]) {
+		top.laptops[0] = laptop
+		heap.Fix(top, 0)
+	}
+}
+
+// sorted returns the kept laptops from best to worst ranked
+func (top *topLaptops) sorted() []*pb.Laptop {
+	laptops := top.laptops
+	sort.Slice(laptops, func(i, j int) bool {
+		return top.less(laptops[i], laptops[j])
+	})
+
+	return laptops
+}
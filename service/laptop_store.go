@@ -3,31 +3,95 @@ package service
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/Adetunjii/go-grpc/pb"
-	"github.com/jinzhu/copier"
+	"github.com/Adetunjii/go-grpc/units"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"log"
+	"strings"
 	"sync"
+	"time"
 )
 
 var DuplicateException = errors.New("resource already exists")
+var NotFoundException = errors.New("resource not found")
+var VersionMismatchException = errors.New("resource version doesn't match")
 
 type LaptopStore interface {
+	// Save stores a copy of the laptop at version 1 and outside the trash,
+	// DuplicateException is returned if a laptop with the same id is stored, even in the trash
 	Save(laptop *pb.Laptop) error
 	FindById(laptopId string) (*pb.Laptop, error)
 
+	// Update overwrites the masked fields of the stored laptop with the same id
+	// and returns the updated laptop.
+	// A non-zero expectedVersion must match the stored version or VersionMismatchException is returned.
+	Update(laptop *pb.Laptop, mask *fieldmaskpb.FieldMask, expectedVersion uint64) (*pb.Laptop, error)
+
+	// Delete moves the laptop to the trash by setting its deleted_at timestamp.
+	// Deleted laptops are hidden from FindById and Search until they are restored or purged.
+	Delete(laptopId string, expectedVersion uint64) error
+
+	// Restore takes the laptop out of the trash and returns it,
+	// a non-zero expectedVersion must match the version of the deleted laptop like for Delete
+	Restore(laptopId string, expectedVersion uint64) (*pb.Laptop, error)
+	ListDeleted(ctx context.Context, found func(laptop *pb.Laptop) error) error
+
+	// Purge permanently removes the laptops deleted before the given time and returns their ids
+	Purge(deletedBefore time.Time) ([]string, error)
+
+	// Search calls found with every laptop matching the filter.
+	// found may be slow, e.g. sending over the network, so it must not be called while holding a lock writers need.
 	Search(ctx context.Context, filter *pb.Filter, found func(latptop *pb.Laptop) error) error
+
+	// SearchText finds the laptops matching the filter whose brand, name, cpu or gpu names contain
+	// every word of the query, in any order and possibly as a prefix.
+	// Each laptop is passed along with its relevance, a query without any word matches every laptop with a zero relevance.
+	SearchText(ctx context.Context, query string, filter *pb.Filter, found func(laptop *pb.Laptop, relevance float64) error) error
+
+	// List returns at most limit laptops ordered by id, starting right after the given id
+	List(ctx context.Context, afterId string, limit int) ([]*pb.Laptop, error)
+
+	// Watch returns a channel receiving an event for every change made from now on,
+	// each event carries the laptop before the change unless it was just created.
+	// The channel is closed when the context is done or the watcher falls too far behind.
+	Watch(ctx context.Context) <-chan *pb.LaptopEvent
 }
 
 // store laptops in memory
 type InMemoryLaptopStore struct {
-	mutex sync.RWMutex // to handle concurrency while saving
-	data  map[string]*pb.Laptop
+	mutex sync.RWMutex          // to handle concurrency while saving
+	data  map[string]*pb.Laptop // stored laptops are replaced on every change, never modified in place
+	ids   *laptopIndex          // every stored laptop ordered by id, used for paging
+	hub   *laptopEventHub
+
+	// secondary indexes on the fields listed in indexedFields, in the same order
+	indexes []*laptopIndex
+	text    *textIndex
+
+	// writeAhead is called with every change before it is applied, while holding the mutex.
+	// If it fails the change is dropped and the error returned, FileLaptopStore logs the changes with it.
+	writeAhead func(records ...*pb.LaptopLogRecord) error
 }
 
 func NewInMemoryLaptopStore() *InMemoryLaptopStore {
+	return newInMemoryLaptopStore(newLaptopEventHub())
+}
+
+// newInMemoryLaptopStore creates a store publishing its changes to the given hub, which may be shared with other stores
+func newInMemoryLaptopStore(hub *laptopEventHub) *InMemoryLaptopStore {
+	indexes := make([]*laptopIndex, len(indexedFields))
+	for i := range indexes {
+		indexes[i] = newLaptopIndex()
+	}
+
 	return &InMemoryLaptopStore{
-		data: make(map[string]*pb.Laptop),
+		data:    make(map[string]*pb.Laptop),
+		ids:     newLaptopIndex(),
+		hub:     hub,
+		indexes: indexes,
+		text:    newTextIndex(),
 	}
 }
 
@@ -39,22 +103,185 @@ func (store *InMemoryLaptopStore) Save(laptop *pb.Laptop) error {
 		return DuplicateException
 	}
 
-	other := &pb.Laptop{}
-	err := copier.Copy(other, laptop)
+	other, err := deepCopy(laptop)
 	if err != nil {
-		return fmt.Errorf("cannot copy laptop data: %v", err)
+		return err
+	}
+
+	// a new laptop starts at the first version and outside the trash, whatever the caller sent
+	other.Version = 1
+	other.DeletedAt = nil
+
+	if err := store.persist(putRecord(other)); err != nil {
+		return err
 	}
 
 	store.data[other.Id] = other
+	store.indexLaptop(other)
+	store.hub.publish(pb.LaptopEvent_CREATED, nil, other)
+	return nil
+}
+
+func (store *InMemoryLaptopStore) Update(laptop *pb.Laptop, mask *fieldmaskpb.FieldMask, expectedVersion uint64) (*pb.Laptop, error) {
+	if err := validateLaptopMask(mask); err != nil {
+		return nil, err
+	}
+
+	src, err := deepCopy(laptop)
+	if err != nil {
+		return nil, err
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	existing := store.data[laptop.Id]
+	if existing == nil || isDeleted(existing) {
+		return nil, NotFoundException
+	}
+
+	if !versionMatches(existing, expectedVersion) {
+		return nil, VersionMismatchException
+	}
+
+	// stored laptops may still be referenced by readers, so merge into a fresh copy
+	updated, err := deepCopy(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	applyLaptopMask(updated, src, mask)
+	updated.UpdatedAt = timestamppb.Now()
+	updated.Version = existing.Version + 1
+
+	if err := store.persist(putRecord(updated)); err != nil {
+		return nil, err
+	}
+
+	store.unindexLaptop(existing)
+	store.data[updated.Id] = updated
+	store.indexLaptop(updated)
+	store.hub.publish(pb.LaptopEvent_UPDATED, existing, updated)
+	return deepCopy(updated)
+}
+
+func (store *InMemoryLaptopStore) Delete(id string, expectedVersion uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	existing := store.data[id]
+	if existing == nil || isDeleted(existing) {
+		return NotFoundException
+	}
+
+	if !versionMatches(existing, expectedVersion) {
+		return VersionMismatchException
+	}
+
+	deleted, err := deepCopy(existing)
+	if err != nil {
+		return err
+	}
+
+	deleted.DeletedAt = timestamppb.Now()
+	deleted.Version = existing.Version + 1
+
+	if err := store.persist(putRecord(deleted)); err != nil {
+		return err
+	}
+
+	store.data[id] = deleted
+	store.hub.publish(pb.LaptopEvent_DELETED, existing, deleted)
 	return nil
 }
 
+func (store *InMemoryLaptopStore) Restore(id string, expectedVersion uint64) (*pb.Laptop, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	existing := store.data[id]
+	if existing == nil || !isDeleted(existing) {
+		return nil, NotFoundException
+	}
+
+	if !versionMatches(existing, expectedVersion) {
+		return nil, VersionMismatchException
+	}
+
+	restored, err := deepCopy(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	restored.DeletedAt = nil
+	restored.Version = existing.Version + 1
+
+	if err := store.persist(putRecord(restored)); err != nil {
+		return nil, err
+	}
+
+	store.data[id] = restored
+	store.hub.publish(pb.LaptopEvent_RESTORED, existing, restored)
+	return deepCopy(restored)
+}
+
+func (store *InMemoryLaptopStore) ListDeleted(ctx context.Context, found func(laptop *pb.Laptop) error) error {
+	store.mutex.RLock()
+	deleted := []*pb.Laptop{}
+	for _, laptop := range store.data {
+		if isDeleted(laptop) {
+			deleted = append(deleted, laptop)
+		}
+	}
+	store.mutex.RUnlock()
+
+	return emitLaptops(ctx, deleted, found)
+}
+
+func (store *InMemoryLaptopStore) Purge(deletedBefore time.Time) ([]string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	purged := []string{}
+	records := []*pb.LaptopLogRecord{}
+	for id, laptop := range store.data {
+		if isDeleted(laptop) && laptop.GetDeletedAt().AsTime().Before(deletedBefore) {
+			purged = append(purged, id)
+			records = append(records, &pb.LaptopLogRecord{Change: &pb.LaptopLogRecord_RemovedId{RemovedId: id}})
+		}
+	}
+
+	if len(purged) == 0 {
+		return purged, nil
+	}
+
+	if err := store.persist(records...); err != nil {
+		return nil, err
+	}
+
+	for _, id := range purged {
+		store.unindexLaptop(store.data[id])
+		delete(store.data, id)
+	}
+
+	return purged, nil
+}
+
+// persist hands the change to writeAhead if it is set, it must be called with the mutex held
+func (store *InMemoryLaptopStore) persist(records ...*pb.LaptopLogRecord) error {
+	if store.writeAhead == nil {
+		return nil
+	}
+
+	return store.writeAhead(records...)
+}
+
 func (store *InMemoryLaptopStore) FindById(id string) (*pb.Laptop, error) {
 	store.mutex.RLock()
 	defer store.mutex.RUnlock()
 
 	laptop := store.data[id]
-	if laptop == nil {
+	if laptop == nil || isDeleted(laptop) {
 		return nil, nil
 	}
 
@@ -62,81 +289,330 @@ func (store *InMemoryLaptopStore) FindById(id string) (*pb.Laptop, error) {
 }
 
 func (store *InMemoryLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(latptop *pb.Laptop) error) error {
+	return store.search(ctx, filter, found, true)
+}
+
+// search walks the most selective secondary index constraining the filter,
+// or every stored laptop if useIndexes is false or no index applies.
+// The matching laptops are collected under the read lock, which is released before calling found:
+// stored laptops are never modified, so the snapshot stays consistent while writers carry on.
+func (store *InMemoryLaptopStore) search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error, useIndexes bool) error {
+	matches, err := store.snapshot(ctx, filter, useIndexes)
+	if err != nil {
+		return err
+	}
+
+	return emitLaptops(ctx, matches, found)
+}
+
+func (store *InMemoryLaptopStore) snapshot(ctx context.Context, filter *pb.Filter, useIndexes bool) ([]*pb.Laptop, error) {
 	store.mutex.RLock()
 	defer store.mutex.RUnlock()
 
+	matches := []*pb.Laptop{}
+	visit := func(laptop *pb.Laptop) error {
+		if ctx.Err() == context.Canceled || ctx.Err() == context.DeadlineExceeded {
+			log.Print("context is cancelled")
+			return errors.New("context is cancelled")
+		}
+
+		if !isDeleted(laptop) && isQualified(filter, laptop) {
+			matches = append(matches, laptop)
+		}
+		return nil
+	}
+
+	if useIndexes {
+		index, min, max, ok := store.selectIndex(filter)
+		if ok {
+			err := index.ascendRange(min, max, func(id string) error {
+				return visit(store.data[id])
+			})
+			if err != nil {
+				return nil, err
+			}
+			return matches, nil
+		}
+	}
+
 	for _, laptop := range store.data {
+		err := visit(laptop)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		//time.Sleep(time.Second)
-		log.Print("checking laptop id: ", laptop.GetId())
+	return matches, nil
+}
 
+// emitLaptops passes a copy of every laptop to found, it must be called without holding the lock
+func emitLaptops(ctx context.Context, laptops []*pb.Laptop, found func(laptop *pb.Laptop) error) error {
+	for _, laptop := range laptops {
 		if ctx.Err() == context.Canceled || ctx.Err() == context.DeadlineExceeded {
 			log.Print("context is cancelled")
 			return errors.New("context is cancelled")
 		}
 
-		if isQualified(filter, laptop) {
-			other, err := deepCopy(laptop)
-			if err != nil {
-				return err
-			}
+		other, err := deepCopy(laptop)
+		if err != nil {
+			return err
+		}
 
-			err = found(other)
-			if err != nil {
-				return err
-			}
+		err = found(other)
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func isQualified(filter *pb.Filter, laptop *pb.Laptop) bool {
-	if laptop.GetPriceUsd() > filter.GetMaxPriceUsd() {
-		return false
+func (store *InMemoryLaptopStore) SearchText(ctx context.Context, query string, filter *pb.Filter, found func(laptop *pb.Laptop, relevance float64) error) error {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return store.Search(ctx, filter, func(laptop *pb.Laptop) error {
+			return found(laptop, 0)
+		})
 	}
 
-	if laptop.GetCpu().GetNumberOfCores() < filter.GetMinCpuCores() {
-		return false
+	matches := store.matchText(terms, filter, store.textStats(terms))
+	return emitTextMatches(ctx, matches, found)
+}
+
+type textMatch struct {
+	laptop    *pb.Laptop
+	relevance float64
+}
+
+// textStats returns the statistics of the text index needed to score the terms
+func (store *InMemoryLaptopStore) textStats(terms []string) textStats {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return store.text.stats(terms)
+}
+
+// matchText collects the laptops matching the terms and the filter under the read lock,
+// their relevance is computed from the given statistics
+func (store *InMemoryLaptopStore) matchText(terms []string, filter *pb.Filter, stats textStats) []textMatch {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	matches := []textMatch{}
+	for id, relevance := range store.text.match(terms, stats) {
+		laptop := store.data[id]
+		if !isDeleted(laptop) && isQualified(filter, laptop) {
+			matches = append(matches, textMatch{laptop: laptop, relevance: relevance})
+		}
 	}
 
-	if laptop.GetCpu().GetMinGhz() < filter.GetMinCupGhb() {
-		return false
+	return matches
+}
+
+// emitTextMatches passes a copy of every matching laptop to found, as for Search it must be called without holding the lock
+func emitTextMatches(ctx context.Context, matches []textMatch, found func(laptop *pb.Laptop, relevance float64) error) error {
+	for _, m := range matches {
+		if ctx.Err() == context.Canceled || ctx.Err() == context.DeadlineExceeded {
+			log.Print("context is cancelled")
+			return errors.New("context is cancelled")
+		}
+
+		other, err := deepCopy(m.laptop)
+		if err != nil {
+			return err
+		}
+
+		err = found(other, m.relevance)
+		if err != nil {
+			return err
+		}
 	}
 
-	if toBit(laptop.GetRam()) < toBit(filter.GetMinRam()) {
-		return false
+	return nil
+}
+
+// selectIndex returns the index matching the fewest laptops for the filter along with its key range,
+// ok is false if none of the indexed fields is constrained
+func (store *InMemoryLaptopStore) selectIndex(filter *pb.Filter) (index *laptopIndex, min float64, max float64, ok bool) {
+	best := -1
+
+	for i, field := range indexedFields {
+		fieldMin, fieldMax, constrained := field.bounds(filter)
+		if !constrained {
+			continue
+		}
+
+		from, to := rangeEntries(fieldMin, fieldMax)
+		count := store.indexes[i].count(from, to)
+		if !ok || count < best {
+			index, min, max, ok = store.indexes[i], fieldMin, fieldMax, true
+			best = count
+		}
 	}
 
-	return true
+	return index, min, max, ok
 }
 
-func toBit(memory *pb.Memory) uint64 {
-	value := memory.GetValue()
+func (store *InMemoryLaptopStore) List(ctx context.Context, afterId string, limit int) ([]*pb.Laptop, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	laptops := []*pb.Laptop{}
+
+	// ids sorted after afterId are stable no matter what gets inserted before them
+	err := store.ids.ascend(indexEntry{id: afterId}, func(entry indexEntry) error {
+		if len(laptops) >= limit {
+			return errStopAscend
+		}
+
+		if ctx.Err() == context.Canceled || ctx.Err() == context.DeadlineExceeded {
+			log.Print("context is cancelled")
+			return errors.New("context is cancelled")
+		}
+
+		laptop := store.data[entry.id]
+		if entry.id == afterId || isDeleted(laptop) {
+			return nil
+		}
+
+		other, err := deepCopy(laptop)
+		if err != nil {
+			return err
+		}
+
+		laptops = append(laptops, other)
+		return nil
+	})
 
-	switch memory.GetUnit() {
-	case pb.Memory_BIT:
-		return value
-	case pb.Memory_BYTE:
-		return value << 3
-	case pb.Memory_KILOBYTE:
-		return value << 13
-	case pb.Memory_MEGABYTE:
-		return value << 23
-	case pb.Memory_GIGABYTE:
-		return value << 33
-	case pb.Memory_TERABYTE:
-		return value << 43
-	default:
-		return 0
+	if err != nil && !errors.Is(err, errStopAscend) {
+		return nil, err
 	}
+
+	return laptops, nil
 }
 
-func deepCopy(laptop *pb.Laptop) (*pb.Laptop, error) {
-	other := &pb.Laptop{}
-	err := copier.Copy(other, laptop)
+// indexLaptop must be called with the write lock held
+func (store *InMemoryLaptopStore) indexLaptop(laptop *pb.Laptop) {
+	store.ids.insert(indexEntry{id: laptop.GetId()})
+	for i, field := range indexedFields {
+		store.indexes[i].insert(indexEntryOf(field, laptop))
+	}
+	store.text.add(laptop)
+}
+
+// unindexLaptop must be called with the write lock held
+func (store *InMemoryLaptopStore) unindexLaptop(laptop *pb.Laptop) {
+	store.ids.remove(indexEntry{id: laptop.GetId()})
+	for i, field := range indexedFields {
+		store.indexes[i].remove(indexEntryOf(field, laptop))
+	}
+	store.text.remove(laptop)
+}
+
+func (store *InMemoryLaptopStore) Watch(ctx context.Context) <-chan *pb.LaptopEvent {
+	return store.hub.subscribe(ctx)
+}
+
+// stored returns the laptop with the given id as stored, deleted or not.
+// Stored laptops are never modified in place, so it stays valid but must not be modified.
+func (store *InMemoryLaptopStore) stored(id string) *pb.Laptop {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return store.data[id]
+}
+
+// all returns every stored laptop ordered by id, deleted ones included, they must not be modified
+func (store *InMemoryLaptopStore) all() []*pb.Laptop {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	laptops := make([]*pb.Laptop, 0, len(store.data))
+	store.ids.ascend(indexEntry{}, func(entry indexEntry) error {
+		laptops = append(laptops, store.data[entry.id])
+		return nil
+	})
+
+	return laptops
+}
+
+// load stores the laptop as is, version included, replacing the laptop with the same id.
+// No event is published since a previous state is restored rather than changed.
+func (store *InMemoryLaptopStore) load(laptop *pb.Laptop) error {
+	other, err := deepCopy(laptop)
 	if err != nil {
-		return nil, fmt.Errorf("cannot copy laptop data: %v", err)
+		return err
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if existing := store.data[other.Id]; existing != nil {
+		store.unindexLaptop(existing)
+	}
+
+	store.data[other.Id] = other
+	store.indexLaptop(other)
+	return nil
+}
+
+// remove permanently removes the laptop with the given id, if any
+func (store *InMemoryLaptopStore) remove(id string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if existing := store.data[id]; existing != nil {
+		delete(store.data, id)
+		store.unindexLaptop(existing)
+	}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, other := range values {
+		if strings.EqualFold(strings.TrimSpace(other), strings.TrimSpace(value)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func totalSSDBits(laptop *pb.Laptop) uint64 {
+	total := uint64(0)
+	for _, storage := range laptop.GetStorages() {
+		if storage.GetDriver() == pb.Storage_SSD {
+			total += units.MemoryBits(storage.GetMemory())
+		}
+	}
+
+	return total
+}
+
+func maxGPUMemoryBits(laptop *pb.Laptop) uint64 {
+	max := uint64(0)
+	for _, gpu := range laptop.GetGpus() {
+		if bits := units.MemoryBits(gpu.GetMemory()); bits > max {
+			max = bits
+		}
+	}
+
+	return max
+}
+
+func isDeleted(laptop *pb.Laptop) bool {
+	return laptop.GetDeletedAt() != nil
+}
+
+// versionMatches reports whether a write expecting the given version may proceed
+func versionMatches(laptop *pb.Laptop, expectedVersion uint64) bool {
+	return expectedVersion == 0 || laptop.GetVersion() == expectedVersion
+}
+
+// deepCopy clones the laptop so that no nested message is shared with the original
+func deepCopy(laptop *pb.Laptop) (*pb.Laptop, error) {
+	other, ok := proto.Clone(laptop).(*pb.Laptop)
+	if !ok {
+		return nil, errors.New("cannot copy laptop data")
 	}
 
 	return other, nil
@@ -0,0 +1,519 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/sample"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInMemoryLaptopStore_ConcurrentUpdate(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+
+	const writers = 50
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			changes := &pb.Laptop{Id: laptop.Id, PriceUsd: float64(i)}
+			_, err := store.Update(changes, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 0)
+			assert.NoError(t, err)
+		}(i)
+
+		go func(i int) {
+			defer wg.Done()
+			changes := &pb.Laptop{Id: laptop.Id, ReleaseYear: uint32(2000 + i)}
+			_, err := store.Update(changes, &fieldmaskpb.FieldMask{Paths: []string{"release_year"}}, 0)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	other, err := store.FindById(laptop.Id)
+	require.NoError(t, err)
+	require.Less(t, other.PriceUsd, float64(writers))
+	require.GreaterOrEqual(t, other.ReleaseYear, uint32(2000))
+	require.Equal(t, laptop.Brand, other.Brand)
+	require.Equal(t, laptop.Cpu.Name, other.Cpu.Name)
+	require.EqualValues(t, 1+2*writers, other.Version)
+}
+
+func TestInMemoryLaptopStore_OptimisticConcurrency(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+
+	saved, err := store.FindById(laptop.Id)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, saved.Version)
+
+	const writers = 20
+	wg := sync.WaitGroup{}
+	results := make(chan error, writers)
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			changes := &pb.Laptop{Id: laptop.Id, PriceUsd: float64(i)}
+			_, err := store.Update(changes, mask, saved.Version)
+			results <- err
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.ErrorIs(t, err, VersionMismatchException)
+	}
+	require.Equal(t, 1, succeeded)
+
+	err = store.Delete(laptop.Id, saved.Version)
+	require.ErrorIs(t, err, VersionMismatchException)
+
+	err = store.Delete(laptop.Id, saved.Version+1)
+	require.NoError(t, err)
+}
+
+func TestInMemoryLaptopStore_SlowWatcherDoesNotBlockWriters(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	slow := store.Watch(ctx)
+
+	saved := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*watchBufferSize; i++ {
+			assert.NoError(t, store.Save(sample.NewLaptop()))
+		}
+		close(saved)
+	}()
+
+	select {
+	case <-saved:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "writers are blocked by a slow watcher")
+	}
+
+	// the slow watcher got a full buffer and was then disconnected
+	count := 0
+	for range slow {
+		count++
+	}
+	require.Equal(t, watchBufferSize, count)
+
+	// new watchers are unaffected
+	events := store.Watch(ctx)
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+
+	event := <-events
+	require.Equal(t, pb.LaptopEvent_CREATED, event.GetType())
+	require.Equal(t, laptop.Id, event.GetLaptop().GetId())
+
+	cancel()
+	_, ok := <-events
+	require.False(t, ok)
+}
+
+func TestInMemoryLaptopStore_StalledSearchDoesNotBlockWriters(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		search func(store *InMemoryLaptopStore, found func(laptop *pb.Laptop) error) error
+	}{
+		{
+			name: "search",
+			search: func(store *InMemoryLaptopStore, found func(laptop *pb.Laptop) error) error {
+				return store.Search(context.Background(), &pb.Filter{MinCpuCores: 1}, found)
+			},
+		},
+		{
+			name: "full_scan",
+			search: func(store *InMemoryLaptopStore, found func(laptop *pb.Laptop) error) error {
+				return store.Search(context.Background(), nil, found)
+			},
+		},
+		{
+			name: "search_text",
+			search: func(store *InMemoryLaptopStore, found func(laptop *pb.Laptop) error) error {
+				return store.SearchText(context.Background(), "macbook", nil, func(laptop *pb.Laptop, relevance float64) error {
+					return found(laptop)
+				})
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := NewInMemoryLaptopStore()
+			laptops := make([]*pb.Laptop, 10)
+			for i := range laptops {
+				laptops[i] = sample.NewLaptop()
+				require.NoError(t, store.Save(laptops[i]))
+			}
+
+			// found stalls on the first laptop, like a client that stopped reading its stream
+			stalled := make(chan struct{})
+			release := make(chan struct{})
+			searched := make(chan []string)
+
+			go func() {
+				ids := []string{}
+				err := tc.search(store, func(laptop *pb.Laptop) error {
+					if len(ids) == 0 {
+						close(stalled)
+						<-release
+					}
+					ids = append(ids, laptop.GetId())
+					return nil
+				})
+				assert.NoError(t, err)
+				searched <- ids
+			}()
+
+			<-stalled
+
+			written := make(chan struct{})
+			go func() {
+				assert.NoError(t, store.Save(sample.NewLaptop()))
+
+				changes := &pb.Laptop{Id: laptops[1].Id, PriceUsd: 1}
+				_, err := store.Update(changes, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 0)
+				assert.NoError(t, err)
+
+				assert.NoError(t, store.Delete(laptops[2].Id, 0))
+				close(written)
+			}()
+
+			select {
+			case <-written:
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "writers are blocked by a stalled search")
+			}
+
+			close(release)
+
+			// the search goes on over the laptops stored when it started
+			ids := <-searched
+			require.Len(t, ids, len(laptops))
+			for _, laptop := range laptops {
+				require.Contains(t, ids, laptop.Id)
+			}
+		})
+	}
+}
+
+func TestIsQualified(t *testing.T) {
+	t.Parallel()
+
+	gigabytes := func(value uint64) *pb.Memory {
+		return &pb.Memory{Value: value, Unit: pb.Memory_GIGABYTE}
+	}
+
+	// every laptop starts from this baseline before the test case modifies it
+	newLaptop := func() *pb.Laptop {
+		laptop := sample.NewLaptop()
+		laptop.Brand = "Dell"
+		laptop.PriceUsd = 1500
+		laptop.ReleaseYear = 2018
+		laptop.Cpu.NumberOfCores = 4
+		laptop.Cpu.MinGhz = 2.5
+		laptop.Ram = gigabytes(16)
+		laptop.Gpus = []*pb.GPU{{Memory: gigabytes(2)}, {Memory: gigabytes(6)}}
+		laptop.Storages = []*pb.Storage{
+			{Driver: pb.Storage_SSD, Memory: gigabytes(256)},
+			{Driver: pb.Storage_SSD, Memory: gigabytes(256)},
+			{Driver: pb.Storage_HDD, Memory: &pb.Memory{Value: 2, Unit: pb.Memory_TERABYTE}},
+		}
+		laptop.Screen = &pb.Screen{
+			SizeInch:   15.6,
+			Panel:      pb.Screen_OLED,
+			Resolution: &pb.Screen_Resolution{Width: 1920, Height: 1080},
+		}
+		laptop.Keyboard = &pb.Keyboard{Layout: pb.Keyboard_QWERTY, Backlit: true}
+		laptop.Weight = &pb.Laptop_WeightKg{WeightKg: 2}
+		return laptop
+	}
+
+	testCases := []struct {
+		name      string
+		filter    *pb.Filter
+		modify    func(laptop *pb.Laptop)
+		qualified bool
+	}{
+		{"nil_filter", nil, nil, true},
+		{"empty_filter", &pb.Filter{}, nil, true},
+		{"max_price_pass", &pb.Filter{MaxPriceUsd: 1500}, nil, true},
+		{"max_price_fail", &pb.Filter{MaxPriceUsd: 1499}, nil, false},
+		{"min_cpu_cores_pass", &pb.Filter{MinCpuCores: 4}, nil, true},
+		{"min_cpu_cores_fail", &pb.Filter{MinCpuCores: 6}, nil, false},
+		{"min_cpu_ghz_pass", &pb.Filter{MinCupGhb: 2.5}, nil, true},
+		{"min_cpu_ghz_fail", &pb.Filter{MinCupGhb: 2.6}, nil, false},
+		{"min_ram_pass", &pb.Filter{MinRam: &pb.Memory{Value: 16384, Unit: pb.Memory_MEGABYTE}}, nil, true},
+		{"min_ram_fail", &pb.Filter{MinRam: gigabytes(32)}, nil, false},
+		{"brands_pass", &pb.Filter{Brands: []string{"Apple", "dell"}}, nil, true},
+		{"brands_fail", &pb.Filter{Brands: []string{"Apple", "Lenovo"}}, nil, false},
+		{"min_release_year_pass", &pb.Filter{MinReleaseYear: 2018}, nil, true},
+		{"min_release_year_fail", &pb.Filter{MinReleaseYear: 2019}, nil, false},
+		{"max_release_year_pass", &pb.Filter{MaxReleaseYear: 2018}, nil, true},
+		{"max_release_year_fail", &pb.Filter{MaxReleaseYear: 2017}, nil, false},
+		{"min_screen_size_pass", &pb.Filter{MinScreenSizeInch: 15}, nil, true},
+		{"min_screen_size_fail", &pb.Filter{MinScreenSizeInch: 16}, nil, false},
+		{"max_screen_size_pass", &pb.Filter{MaxScreenSizeInch: 16}, nil, true},
+		{"max_screen_size_fail", &pb.Filter{MaxScreenSizeInch: 15}, nil, false},
+		{"screen_panel_pass", &pb.Filter{ScreenPanel: pb.Screen_OLED}, nil, true},
+		{"screen_panel_fail", &pb.Filter{ScreenPanel: pb.Screen_OPS}, nil, false},
+		{
+			"min_resolution_pass",
+			&pb.Filter{MinScreenResolution: &pb.Screen_Resolution{Width: 1920, Height: 1080}},
+			nil,
+			true,
+		},
+		{
+			"min_resolution_fail",
+			&pb.Filter{MinScreenResolution: &pb.Screen_Resolution{Width: 1920, Height: 1200}},
+			nil,
+			false,
+		},
+		{"min_ssd_storage_pass", &pb.Filter{MinSsdStorage: gigabytes(512)}, nil, true},
+		{"min_ssd_storage_fail", &pb.Filter{MinSsdStorage: gigabytes(1024)}, nil, false},
+		{"min_gpu_memory_pass", &pb.Filter{MinGpuMemory: gigabytes(6)}, nil, true},
+		{"min_gpu_memory_fail", &pb.Filter{MinGpuMemory: gigabytes(8)}, nil, false},
+		{"keyboard_layout_pass", &pb.Filter{KeyboardLayout: pb.Keyboard_QWERTY}, nil, true},
+		{"keyboard_layout_fail", &pb.Filter{KeyboardLayout: pb.Keyboard_AZERTY}, nil, false},
+		{"backlit_keyboard_pass", &pb.Filter{RequireBacklitKeyboard: true}, nil, true},
+		{
+			"backlit_keyboard_fail",
+			&pb.Filter{RequireBacklitKeyboard: true},
+			func(laptop *pb.Laptop) { laptop.Keyboard.Backlit = false },
+			false,
+		},
+		{"max_weight_kg_pass", &pb.Filter{MaxWeightKg: 2}, nil, true},
+		{"max_weight_kg_fail", &pb.Filter{MaxWeightKg: 1.9}, nil, false},
+		{
+			"max_weight_lb_pass",
+			&pb.Filter{MaxWeightKg: 2},
+			func(laptop *pb.Laptop) { laptop.Weight = &pb.Laptop_WeightLb{WeightLb: 4.4} },
+			true,
+		},
+		{
+			"max_weight_lb_fail",
+			&pb.Filter{MaxWeightKg: 2},
+			func(laptop *pb.Laptop) { laptop.Weight = &pb.Laptop_WeightLb{WeightLb: 4.5} },
+			false,
+		},
+		{
+			"max_weight_missing",
+			&pb.Filter{MaxWeightKg: 2},
+			func(laptop *pb.Laptop) { laptop.Weight = nil },
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			laptop := newLaptop()
+			if tc.modify != nil {
+				tc.modify(laptop)
+			}
+
+			require.Equal(t, tc.qualified, isQualified(tc.filter, laptop))
+		})
+	}
+}
+
+func TestInMemoryLaptopStore_SearchText(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	laptop.Name = "ZenBook Pro-14"
+	require.NoError(t, store.Save(laptop))
+
+	matches := func(query string) bool {
+		found := false
+		err := store.SearchText(context.Background(), query, nil, func(other *pb.Laptop, relevance float64) error {
+			require.Equal(t, laptop.Id, other.Id)
+			require.Greater(t, relevance, 0.0)
+			found = true
+			return nil
+		})
+		require.NoError(t, err)
+		return found
+	}
+
+	require.True(t, matches("zenbook"))
+	require.True(t, matches("ZENB 14"))
+	require.True(t, matches("pro, zen!"))
+	require.False(t, matches("zenbook 15"))
+
+	changes := &pb.Laptop{Id: laptop.Id, Name: "VivoBook"}
+	_, err := store.Update(changes, &fieldmaskpb.FieldMask{Paths: []string{"name"}}, 0)
+	require.NoError(t, err)
+	require.False(t, matches("zen"))
+	require.True(t, matches("vivo"))
+
+	require.NoError(t, store.Delete(laptop.Id, 0))
+	require.False(t, matches("vivo"))
+
+	purged, err := store.Purge(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, []string{laptop.Id}, purged)
+	require.Empty(t, store.text.postings)
+	require.Empty(t, store.text.tokens)
+}
+
+func TestLaptopIndex(t *testing.T) {
+	t.Parallel()
+
+	index := newLaptopIndex()
+
+	// enough entries to split the index into several blocks
+	const n = indexBlockSize * 4
+	for i := n - 1; i >= 0; i-- {
+		index.insert(indexEntry{key: float64(i / 2), id: fmt.Sprintf("%06d", i)})
+	}
+	require.Greater(t, len(index.blocks), 1)
+
+	from, to := rangeEntries(100, 199)
+	require.Equal(t, 200, index.count(from, to))
+
+	for i := 200; i < 400; i += 2 {
+		index.remove(indexEntry{key: float64(i / 2), id: fmt.Sprintf("%06d", i)})
+	}
+	require.Equal(t, 100, index.count(from, to))
+
+	ids := []string{}
+	err := index.ascendRange(100, 199, func(id string) error {
+		ids = append(ids, id)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, ids, 100)
+	require.True(t, sort.StringsAreSorted(ids))
+	require.Equal(t, "000201", ids[0])
+	require.Equal(t, "000399", ids[len(ids)-1])
+}
+
+func TestInMemoryLaptopStore_IndexedSearchMatchesFullScan(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	for i := 0; i < 2000; i++ {
+		require.NoError(t, store.Save(sample.NewLaptop()))
+	}
+
+	laptops, err := store.List(context.Background(), "", 2000)
+	require.NoError(t, err)
+
+	// move some laptops around the indexes and drop others, the indexes must follow
+	for _, laptop := range laptops {
+		if laptop.GetPriceUsd() < 1700 {
+			laptop.PriceUsd += 1000
+			_, err := store.Update(laptop, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 0)
+			require.NoError(t, err)
+		} else if laptop.GetPriceUsd() > 2900 {
+			require.NoError(t, store.Delete(laptop.GetId(), 0))
+		}
+	}
+
+	filters := []*pb.Filter{
+		nil,
+		{MaxPriceUsd: 2000},
+		{MinCpuCores: 6},
+		{MinCupGhb: 3.2, MaxPriceUsd: 2500},
+		{MinRam: &pb.Memory{Value: 48, Unit: pb.Memory_GIGABYTE}, MinCpuCores: 4},
+		{MaxPriceUsd: 2000, MinCpuCores: 8, MinCupGhb: 3, MinRam: &pb.Memory{Value: 32, Unit: pb.Memory_GIGABYTE}},
+	}
+
+	for _, filter := range filters {
+		expected := searchIds(t, store, filter, false)
+		actual := searchIds(t, store, filter, true)
+		require.Equal(t, expected, actual)
+	}
+}
+
+func searchIds(t testing.TB, store *InMemoryLaptopStore, filter *pb.Filter, useIndexes bool) []string {
+	ids := []string{}
+	err := store.search(context.Background(), filter, func(laptop *pb.Laptop) error {
+		ids = append(ids, laptop.GetId())
+		return nil
+	}, useIndexes)
+	require.NoError(t, err)
+
+	sort.Strings(ids)
+	return ids
+}
+
+const benchmarkLaptops = 100000
+
+// a selective filter, about one laptop in two hundred is cheap enough
+var benchmarkFilter = &pb.Filter{
+	MaxPriceUsd: 1510,
+	MinCpuCores: 4,
+	MinRam:      &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE},
+}
+
+func newBenchmarkStore(b *testing.B) *InMemoryLaptopStore {
+	store := NewInMemoryLaptopStore()
+	for i := 0; i < benchmarkLaptops; i++ {
+		require.NoError(b, store.Save(sample.NewLaptop()))
+	}
+
+	return store
+}
+
+func benchmarkSearch(b *testing.B, useIndexes bool) {
+	store := newBenchmarkStore(b)
+	found := func(laptop *pb.Laptop) error {
+		return nil
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := store.search(context.Background(), benchmarkFilter, found, useIndexes)
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkInMemoryLaptopStore_SearchFullScan(b *testing.B) {
+	benchmarkSearch(b, false)
+}
+
+func BenchmarkInMemoryLaptopStore_SearchIndexed(b *testing.B) {
+	benchmarkSearch(b, true)
+}
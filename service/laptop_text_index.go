@@ -0,0 +1,214 @@
+package service
+
+import (
+	"github.com/Adetunjii/go-grpc/pb"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// textField describes a laptop field covered by the full-text index,
+// a token found in a heavier field makes the laptop more relevant
+type textField struct {
+	weight float64
+	values func(laptop *pb.Laptop) []string
+}
+
+var textFields = []textField{
+	{
+		weight: 3,
+		values: func(laptop *pb.Laptop) []string {
+			return []string{laptop.GetName()}
+		},
+	},
+	{
+		weight: 2,
+		values: func(laptop *pb.Laptop) []string {
+			return []string{laptop.GetBrand()}
+		},
+	},
+	{
+		weight: 1,
+		values: func(laptop *pb.Laptop) []string {
+			return []string{laptop.GetCpu().GetName()}
+		},
+	},
+	{
+		weight: 1,
+		values: func(laptop *pb.Laptop) []string {
+			names := make([]string, len(laptop.GetGpus()))
+			for i, gpu := range laptop.GetGpus() {
+				names[i] = gpu.GetName()
+			}
+			return names
+		},
+	},
+}
+
+// tokenize splits the text into lower case words made of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// textIndex is an inverted index from tokens to the laptops containing them
+type textIndex struct {
+	postings map[string]map[string]float64 // token -> laptop id -> field weight
+	tokens   []string                      // every indexed token in order, used for prefix lookups
+	size     int                           // number of indexed laptops
+}
+
+func newTextIndex() *textIndex {
+	return &textIndex{
+		postings: make(map[string]map[string]float64),
+	}
+}
+
+// laptopTokens returns the weight of every token of the laptop
+func laptopTokens(laptop *pb.Laptop) map[string]float64 {
+	tokens := make(map[string]float64)
+	for _, field := range textFields {
+		for _, value := range field.values(laptop) {
+			for _, token := range tokenize(value) {
+				tokens[token] += field.weight
+			}
+		}
+	}
+
+	return tokens
+}
+
+func (index *textIndex) add(laptop *pb.Laptop) {
+	index.size++
+
+	for token, weight := range laptopTokens(laptop) {
+		posting := index.postings[token]
+		if posting == nil {
+			posting = make(map[string]float64)
+			index.postings[token] = posting
+
+			i := sort.SearchStrings(index.tokens, token)
+			index.tokens = append(index.tokens, "")
+			copy(index.tokens[i+1:], index.tokens[i:])
+			index.tokens[i] = token
+		}
+
+		posting[laptop.GetId()] = weight
+	}
+}
+
+func (index *textIndex) remove(laptop *pb.Laptop) {
+	index.size--
+
+	for token := range laptopTokens(laptop) {
+		posting := index.postings[token]
+		delete(posting, laptop.GetId())
+
+		if len(posting) == 0 {
+			delete(index.postings, token)
+
+			i := sort.SearchStrings(index.tokens, token)
+			if i < len(index.tokens) && index.tokens[i] == token {
+				index.tokens = append(index.tokens[:i], index.tokens[i+1:]...)
+			}
+		}
+	}
+}
+
+// textStats holds what the relevance of a term depends on: the number of indexed laptops
+// and the number of laptops containing each token. Stores split in several indexes add up
+// the statistics of every index so that relevances are comparable across them.
+type textStats struct {
+	size        int
+	frequencies map[string]int // token -> number of laptops containing it
+}
+
+// stats returns the statistics of the index for every token starting with one of the terms
+func (index *textIndex) stats(terms []string) textStats {
+	stats := textStats{size: index.size, frequencies: make(map[string]int)}
+	for _, term := range terms {
+		for i := sort.SearchStrings(index.tokens, term); i < len(index.tokens) && strings.HasPrefix(index.tokens[i], term); i++ {
+			stats.frequencies[index.tokens[i]] = len(index.postings[index.tokens[i]])
+		}
+	}
+
+	return stats
+}
+
+func (stats textStats) add(other textStats) textStats {
+	sum := textStats{size: stats.size + other.size, frequencies: make(map[string]int)}
+	for token, frequency := range stats.frequencies {
+		sum.frequencies[token] += frequency
+	}
+	for token, frequency := range other.frequencies {
+		sum.frequencies[token] += frequency
+	}
+
+	return sum
+}
+
+// match returns the relevance of every laptop containing all the terms,
+// either as a whole token or as the prefix of one
+func (index *textIndex) match(terms []string, stats textStats) map[string]float64 {
+	var scores map[string]float64
+
+	for _, term := range terms {
+		termScores := index.matchTerm(term, stats)
+
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+
+		for id := range scores {
+			score, ok := termScores[id]
+			if !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] += score
+		}
+	}
+
+	return scores
+}
+
+// matchTerm scores every laptop having a token starting with the term,
+// rare tokens weigh more and prefix matches weigh less than whole tokens
+func (index *textIndex) matchTerm(term string, stats textStats) map[string]float64 {
+	scores := make(map[string]float64)
+
+	for i := sort.SearchStrings(index.tokens, term); i < len(index.tokens); i++ {
+		token := index.tokens[i]
+		if !strings.HasPrefix(token, term) {
+			break
+		}
+
+		posting := index.postings[token]
+
+		// laptops added since the statistics were taken are counted in,
+		// so that the frequency is never zero nor larger than the size
+		frequency := stats.frequencies[token]
+		if frequency < len(posting) {
+			frequency = len(posting)
+		}
+		size := stats.size
+		if size < frequency {
+			size = frequency
+		}
+
+		idf := math.Log(1 + float64(size)/float64(frequency))
+		coverage := float64(len(term)) / float64(len(token))
+
+		for id, weight := range posting {
+			score := weight * idf * coverage
+			if score > scores[id] {
+				scores[id] = score
+			}
+		}
+	}
+
+	return scores
+}
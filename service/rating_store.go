@@ -0,0 +1,48 @@
+package service
+
+import "sync"
+
+type RatingStore interface {
+	// Add adds a new laptop score to the store and returns the updated rating
+	Add(laptopID string, score float64) (*Rating, error)
+}
+
+type Rating struct {
+	Count uint32
+	Sum   float64
+}
+
+// store laptop ratings in memory
+type InMemoryRatingStore struct {
+	mutex  sync.RWMutex
+	rating map[string]*Rating
+}
+
+func NewInMemoryRatingStore() *InMemoryRatingStore {
+	return &InMemoryRatingStore{
+		rating: make(map[string]*Rating),
+	}
+}
+
+func (store *InMemoryRatingStore) Add(laptopID string, score float64) (*Rating, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	rating := store.rating[laptopID]
+	if rating == nil {
+		rating = &Rating{}
+		store.rating[laptopID] = rating
+	}
+
+	rating.Count++
+	rating.Sum += score
+
+	return rating.Clone(), nil
+}
+
+func (rating *Rating) Clone() *Rating {
+	return &Rating{
+		Count: rating.Count,
+		Sum:   rating.Sum,
+	}
+}
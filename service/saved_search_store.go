@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"github.com/Adetunjii/go-grpc/pb"
+	"google.golang.org/protobuf/proto"
+	"sort"
+	"sync"
+)
+
+type SavedSearchStore interface {
+	// Save stores the search for the user, its id must be unique
+	Save(username string, search *pb.SavedSearch) error
+
+	// List returns the searches saved by the user, oldest first
+	List(username string) ([]*pb.SavedSearch, error)
+
+	// Delete removes a search of the user, NotFoundException is returned
+	// if the user has no search with that id, even if another user has one
+	Delete(username string, searchID string) error
+}
+
+// store saved searches in memory
+type InMemorySavedSearchStore struct {
+	mutex    sync.RWMutex
+	searches map[string]map[string]*pb.SavedSearch // username -> search id -> search
+}
+
+func NewInMemorySavedSearchStore() *InMemorySavedSearchStore {
+	return &InMemorySavedSearchStore{
+		searches: make(map[string]map[string]*pb.SavedSearch),
+	}
+}
+
+func (store *InMemorySavedSearchStore) Save(username string, search *pb.SavedSearch) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, searches := range store.searches {
+		if searches[search.GetId()] != nil {
+			return DuplicateException
+		}
+	}
+
+	other, err := copySavedSearch(search)
+	if err != nil {
+		return err
+	}
+
+	if store.searches[username] == nil {
+		store.searches[username] = make(map[string]*pb.SavedSearch)
+	}
+
+	store.searches[username][other.GetId()] = other
+	return nil
+}
+
+func (store *InMemorySavedSearchStore) List(username string) ([]*pb.SavedSearch, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	searches := make([]*pb.SavedSearch, 0, len(store.searches[username]))
+	for _, search := range store.searches[username] {
+		other, err := copySavedSearch(search)
+		if err != nil {
+			return nil, err
+		}
+
+		searches = append(searches, other)
+	}
+
+	sort.Slice(searches, func(i, j int) bool {
+		ti, tj := searches[i].GetCreatedAt().AsTime(), searches[j].GetCreatedAt().AsTime()
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return searches[i].GetId() < searches[j].GetId()
+	})
+
+	return searches, nil
+}
+
+func (store *InMemorySavedSearchStore) Delete(username string, searchID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.searches[username][searchID] == nil {
+		return NotFoundException
+	}
+
+	delete(store.searches[username], searchID)
+	if len(store.searches[username]) == 0 {
+		delete(store.searches, username)
+	}
+
+	return nil
+}
+
+func copySavedSearch(search *pb.SavedSearch) (*pb.SavedSearch, error) {
+	other, ok := proto.Clone(search).(*pb.SavedSearch)
+	if !ok {
+		return nil, errors.New("cannot copy saved search data")
+	}
+
+	return other, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"github.com/Adetunjii/go-grpc/pb"
+	"sort"
+)
+
+// laptopCheck is a criterion of a search bound to its parameters
+type laptopCheck struct {
+	criterion string
+	passes    func(laptop *pb.Laptop) bool
+}
+
+// searchExplainer runs every check of a search on each laptop
+// and keeps track of the criteria rejecting them
+type searchExplainer struct {
+	checks        []laptopCheck
+	maxNearMisses int
+
+	scanned    uint32
+	matched    uint32
+	rejected   map[string]uint32
+	nearMisses []*pb.NearMiss // ordered by laptop id
+}
+
+func newSearchExplainer(filter *pb.Filter, maxNearMisses int) *searchExplainer {
+	explainer := &searchExplainer{
+		maxNearMisses: maxNearMisses,
+		rejected:      make(map[string]uint32),
+	}
+
+	for _, check := range filterChecks {
+		check := check
+		explainer.addCheck(check.criterion, func(laptop *pb.Laptop) bool {
+			return check.passes(filter, laptop)
+		})
+	}
+
+	return explainer
+}
+
+// addCheck adds a criterion that isn't part of the filter, such as the text query
+func (explainer *searchExplainer) addCheck(criterion string, passes func(laptop *pb.Laptop) bool) {
+	explainer.checks = append(explainer.checks, laptopCheck{criterion: criterion, passes: passes})
+}
+
+func (explainer *searchExplainer) add(laptop *pb.Laptop) {
+	explainer.scanned++
+
+	failed := []string{}
+	for _, check := range explainer.checks {
+		if !check.passes(laptop) {
+			failed = append(failed, check.criterion)
+			explainer.rejected[check.criterion]++
+		}
+	}
+
+	switch {
+	case len(failed) == 0:
+		explainer.matched++
+	case len(failed) == 1:
+		explainer.addNearMiss(&pb.NearMiss{
+			Laptop:          laptop,
+			FailedCriterion: failed[0],
+		})
+	}
+}
+
+// addNearMiss keeps the near misses with the smallest laptop ids,
+// so that the explanation doesn't depend on the order the store visits laptops in
+func (explainer *searchExplainer) addNearMiss(nearMiss *pb.NearMiss) {
+	id := nearMiss.GetLaptop().GetId()
+	i := sort.Search(len(explainer.nearMisses), func(i int) bool {
+		return explainer.nearMisses[i].GetLaptop().GetId() > id
+	})
+
+	if i >= explainer.maxNearMisses {
+		return
+	}
+
+	explainer.nearMisses = append(explainer.nearMisses, nil)
+	copy(explainer.nearMisses[i+1:], explainer.nearMisses[i:])
+	explainer.nearMisses[i] = nearMiss
+
+	if len(explainer.nearMisses) > explainer.maxNearMisses {
+		explainer.nearMisses = explainer.nearMisses[:explainer.maxNearMisses]
+	}
+}
+
+func (explainer *searchExplainer) toProto() *pb.SearchExplanation {
+	explanation := &pb.SearchExplanation{
+		ScannedCount: explainer.scanned,
+		MatchedCount: explainer.matched,
+		NearMisses:   explainer.nearMisses,
+	}
+
+	// criteria are listed in the order they are checked
+	for _, check := range explainer.checks {
+		if count := explainer.rejected[check.criterion]; count > 0 {
+			explanation.Rejections = append(explanation.Rejections, &pb.CriterionRejection{
+				Criterion:     check.criterion,
+				RejectedCount: count,
+			})
+		}
+	}
+
+	return explanation
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/Adetunjii/go-grpc/pb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"hash/fnv"
+	"log"
+	"sort"
+	"time"
+)
+
+// DefaultShardCount is the number of shards of a ShardedLaptopStore when none is given
+const DefaultShardCount = 16
+
+// ShardedLaptopStore spreads the laptops over independently locked in-memory shards by hashing their ids,
+// so that writers on different shards don't wait for each other and a search only holds one shard at a time.
+// Searches run on every shard concurrently.
+type ShardedLaptopStore struct {
+	shards []*InMemoryLaptopStore
+	hub    *laptopEventHub // shared by every shard, so that watchers see the changes of all of them
+}
+
+func NewShardedLaptopStore(shardCount int) *ShardedLaptopStore {
+	if shardCount <= 0 {
+		shardCount = DefaultShardCount
+	}
+
+	hub := newLaptopEventHub()
+	shards := make([]*InMemoryLaptopStore, shardCount)
+	for i := range shards {
+		shards[i] = newInMemoryLaptopStore(hub)
+	}
+
+	return &ShardedLaptopStore{
+		shards: shards,
+		hub:    hub,
+	}
+}
+
+// shard returns the shard holding the laptop with the given id
+func (store *ShardedLaptopStore) shard(id string) *InMemoryLaptopStore {
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+
+	return store.shards[hash.Sum32()%uint32(len(store.shards))]
+}
+
+func (store *ShardedLaptopStore) Save(laptop *pb.Laptop) error {
+	return store.shard(laptop.GetId()).Save(laptop)
+}
+
+func (store *ShardedLaptopStore) FindById(id string) (*pb.Laptop, error) {
+	return store.shard(id).FindById(id)
+}
+
+func (store *ShardedLaptopStore) Update(laptop *pb.Laptop, mask *fieldmaskpb.FieldMask, expectedVersion uint64) (*pb.Laptop, error) {
+	return store.shard(laptop.GetId()).Update(laptop, mask, expectedVersion)
+}
+
+func (store *ShardedLaptopStore) Delete(id string, expectedVersion uint64) error {
+	return store.shard(id).Delete(id, expectedVersion)
+}
+
+func (store *ShardedLaptopStore) Restore(id string, expectedVersion uint64) (*pb.Laptop, error) {
+	return store.shard(id).Restore(id, expectedVersion)
+}
+
+func (store *ShardedLaptopStore) ListDeleted(ctx context.Context, found func(laptop *pb.Laptop) error) error {
+	for _, shard := range store.shards {
+		err := shard.ListDeleted(ctx, found)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *ShardedLaptopStore) Purge(deletedBefore time.Time) ([]string, error) {
+	purged := []string{}
+	for _, shard := range store.shards {
+		ids, err := shard.Purge(deletedBefore)
+		if err != nil {
+			return nil, err
+		}
+
+		purged = append(purged, ids...)
+	}
+
+	return purged, nil
+}
+
+// fanOut calls visit on every shard concurrently and waits for all of them,
+// unless the context is done first. visit must only write to its own shard's part of the results.
+func (store *ShardedLaptopStore) fanOut(ctx context.Context, visit func(i int, shard *InMemoryLaptopStore) error) error {
+	// buffered so that shards finishing after the context is done don't block forever
+	done := make(chan error, len(store.shards))
+	for i, shard := range store.shards {
+		go func(i int, shard *InMemoryLaptopStore) {
+			done <- visit(i, shard)
+		}(i, shard)
+	}
+
+	for range store.shards {
+		select {
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			log.Print("context is cancelled")
+			return errors.New("context is cancelled")
+		}
+	}
+
+	return nil
+}
+
+func (store *ShardedLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(latptop *pb.Laptop) error) error {
+	matches := make([][]*pb.Laptop, len(store.shards))
+	err := store.fanOut(ctx, func(i int, shard *InMemoryLaptopStore) error {
+		var err error
+		matches[i], err = shard.snapshot(ctx, filter, true)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// found is called from this goroutine only, once every shard lock is released
+	for _, laptops := range matches {
+		err := emitLaptops(ctx, laptops, found)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *ShardedLaptopStore) SearchText(ctx context.Context, query string, filter *pb.Filter, found func(laptop *pb.Laptop, relevance float64) error) error {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return store.Search(ctx, filter, func(laptop *pb.Laptop) error {
+			return found(laptop, 0)
+		})
+	}
+
+	// relevances depend on the whole catalog, so every shard scores with the statistics of all of them
+	shardStats := make([]textStats, len(store.shards))
+	err := store.fanOut(ctx, func(i int, shard *InMemoryLaptopStore) error {
+		shardStats[i] = shard.textStats(terms)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	stats := textStats{}
+	for _, other := range shardStats {
+		stats = stats.add(other)
+	}
+
+	matches := make([][]textMatch, len(store.shards))
+	err = store.fanOut(ctx, func(i int, shard *InMemoryLaptopStore) error {
+		matches[i] = shard.matchText(terms, filter, stats)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, shardMatches := range matches {
+		err := emitTextMatches(ctx, shardMatches, found)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List merges the first laptops of every shard, which are enough to fill the page
+func (store *ShardedLaptopStore) List(ctx context.Context, afterId string, limit int) ([]*pb.Laptop, error) {
+	pages := make([][]*pb.Laptop, len(store.shards))
+	err := store.fanOut(ctx, func(i int, shard *InMemoryLaptopStore) error {
+		var err error
+		pages[i], err = shard.List(ctx, afterId, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	laptops := []*pb.Laptop{}
+	for _, page := range pages {
+		laptops = append(laptops, page...)
+	}
+
+	sort.Slice(laptops, func(i, j int) bool {
+		return laptops[i].GetId() < laptops[j].GetId()
+	})
+
+	if len(laptops) > limit {
+		laptops = laptops[:limit]
+	}
+
+	return laptops, nil
+}
+
+func (store *ShardedLaptopStore) Watch(ctx context.Context) <-chan *pb.LaptopEvent {
+	return store.hub.subscribe(ctx)
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/sample"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func storeSearchIds(t testing.TB, store LaptopStore, filter *pb.Filter) []string {
+	ids := []string{}
+	err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+		ids = append(ids, laptop.GetId())
+		return nil
+	})
+	require.NoError(t, err)
+
+	sort.Strings(ids)
+	return ids
+}
+
+func storeTextRelevances(t testing.TB, store LaptopStore, query string) map[string]float64 {
+	relevances := make(map[string]float64)
+	err := store.SearchText(context.Background(), query, nil, func(laptop *pb.Laptop, relevance float64) error {
+		relevances[laptop.GetId()] = relevance
+		return nil
+	})
+	require.NoError(t, err)
+
+	return relevances
+}
+
+func TestShardedLaptopStore_MatchesInMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	single := NewInMemoryLaptopStore()
+	sharded := NewShardedLaptopStore(8)
+	stores := []LaptopStore{single, sharded}
+
+	for i := 0; i < 500; i++ {
+		laptop := sample.NewLaptop()
+		for _, store := range stores {
+			require.NoError(t, store.Save(laptop))
+		}
+	}
+
+	laptops, err := single.List(context.Background(), "", 500)
+	require.NoError(t, err)
+
+	for i, laptop := range laptops {
+		for _, store := range stores {
+			switch i % 5 {
+			case 0:
+				laptop.PriceUsd += 1000
+				_, err := store.Update(laptop, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 0)
+				require.NoError(t, err)
+				laptop.PriceUsd -= 1000
+			case 1:
+				require.NoError(t, store.Delete(laptop.GetId(), 0))
+			}
+		}
+	}
+
+	filters := []*pb.Filter{
+		nil,
+		{MaxPriceUsd: 2000},
+		{MinCpuCores: 6, MinRam: &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}},
+	}
+	for _, filter := range filters {
+		require.Equal(t, storeSearchIds(t, single, filter), storeSearchIds(t, sharded, filter))
+	}
+
+	// relevances are computed over the whole catalog, not per shard
+	for _, query := range []string{"macbook", "apple core i", "rtx"} {
+		expected := storeTextRelevances(t, single, query)
+		actual := storeTextRelevances(t, sharded, query)
+		require.Len(t, actual, len(expected))
+		for id, relevance := range expected {
+			require.InDelta(t, relevance, actual[id], 1e-9)
+		}
+	}
+
+	// paging through the sharded store gives the same pages
+	afterId := ""
+	for {
+		expected, err := single.List(context.Background(), afterId, 37)
+		require.NoError(t, err)
+		actual, err := sharded.List(context.Background(), afterId, 37)
+		require.NoError(t, err)
+
+		require.Len(t, actual, len(expected))
+		for i := range expected {
+			require.Equal(t, expected[i].GetId(), actual[i].GetId())
+		}
+
+		if len(expected) == 0 {
+			break
+		}
+		afterId = expected[len(expected)-1].GetId()
+	}
+
+	deletedIds := func(store LaptopStore) []string {
+		ids := []string{}
+		err := store.ListDeleted(context.Background(), func(laptop *pb.Laptop) error {
+			ids = append(ids, laptop.GetId())
+			return nil
+		})
+		require.NoError(t, err)
+
+		sort.Strings(ids)
+		return ids
+	}
+	require.Len(t, deletedIds(sharded), 100)
+	require.Equal(t, deletedIds(single), deletedIds(sharded))
+
+	purged, err := sharded.Purge(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, purged, 100)
+	require.Empty(t, deletedIds(sharded))
+}
+
+func TestShardedLaptopStore_ConcurrentSaveAndSearch(t *testing.T) {
+	t.Parallel()
+
+	store := NewShardedLaptopStore(4)
+	events := store.Watch(context.Background())
+
+	// every event fits in the buffer of the watcher, which is only read at the end
+	const writers = 8
+	const laptopsPerWriter = watchBufferSize / writers
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < laptopsPerWriter; j++ {
+				assert.NoError(t, store.Save(sample.NewLaptop()))
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < laptopsPerWriter; j++ {
+				err := store.Search(context.Background(), nil, func(laptop *pb.Laptop) error {
+					return nil
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, storeSearchIds(t, store, nil), writers*laptopsPerWriter)
+
+	// a single watcher sees the changes of every shard
+	for i := 0; i < writers*laptopsPerWriter; i++ {
+		event := <-events
+		require.Equal(t, pb.LaptopEvent_CREATED, event.GetType())
+	}
+}
+
+func TestShardedLaptopStore_CancelledSearch(t *testing.T) {
+	t.Parallel()
+
+	store := NewShardedLaptopStore(4)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, store.Save(sample.NewLaptop()))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	found := 0
+	err := store.Search(ctx, nil, func(laptop *pb.Laptop) error {
+		found++
+		return nil
+	})
+	require.Error(t, err)
+	require.Zero(t, found)
+
+	err = store.SearchText(ctx, "macbook", nil, func(laptop *pb.Laptop, relevance float64) error {
+		found++
+		return nil
+	})
+	require.Error(t, err)
+	require.Zero(t, found)
+}
+
+const parallelBenchmarkLaptops = 20000
+
+func newParallelBenchmarkStores(b *testing.B) map[string]LaptopStore {
+	stores := map[string]LaptopStore{
+		"InMemory": NewInMemoryLaptopStore(),
+		"Sharded":  NewShardedLaptopStore(DefaultShardCount),
+	}
+
+	for i := 0; i < parallelBenchmarkLaptops; i++ {
+		laptop := sample.NewLaptop()
+		for _, store := range stores {
+			require.NoError(b, store.Save(laptop))
+		}
+	}
+
+	return stores
+}
+
+// longSearchFilter isn't covered by any index, so a search reads every laptop while holding the lock
+var longSearchFilter = &pb.Filter{MaxWeightKg: 0.1}
+
+// benchmarkParallel runs saves from many goroutines on both stores while searchers goroutines keep running
+// long searches, as when a catalog is browsed while it is being filled.
+// A save waits for the search holding the lock of the single store, but only for a shard of the sharded one.
+func benchmarkParallel(b *testing.B, searchers int) {
+	stores := newParallelBenchmarkStores(b)
+
+	for _, name := range []string{"InMemory", "Sharded"} {
+		store := stores[name]
+		b.Run(name, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			wg := sync.WaitGroup{}
+			started := sync.WaitGroup{}
+			searches := make([]int, searchers)
+
+			for i := 0; i < searchers; i++ {
+				wg.Add(1)
+				started.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					started.Done()
+					for ctx.Err() == nil {
+						err := store.Search(ctx, longSearchFilter, func(laptop *pb.Laptop) error {
+							return nil
+						})
+						// the search cancelled at the end is not counted
+						if err == nil {
+							searches[i]++
+						}
+					}
+				}(i)
+			}
+			// the saves must not get done before the searches begin
+			started.Wait()
+
+			b.ResetTimer()
+			start := time.Now()
+			b.RunParallel(func(parallel *testing.PB) {
+				// Save copies the laptop, so one is enough per goroutine
+				laptop := sample.NewLaptop()
+
+				for parallel.Next() {
+					laptop.Id = uuid.New().String()
+					assert.NoError(b, store.Save(laptop))
+				}
+			})
+			b.StopTimer()
+			elapsed := time.Since(start)
+
+			cancel()
+			wg.Wait()
+
+			if searchers > 0 {
+				total := 0
+				for _, count := range searches {
+					total += count
+				}
+				b.ReportMetric(float64(total)/elapsed.Seconds(), "searches/s")
+			}
+		})
+	}
+}
+
+func BenchmarkLaptopStore_ParallelSave(b *testing.B) {
+	benchmarkParallel(b, 0)
+}
+
+// BenchmarkLaptopStore_ParallelSaveDuringSearches is best run with a fixed -benchtime such as 2000x,
+// since every save to the single store waits for a whole search
+func BenchmarkLaptopStore_ParallelSaveDuringSearches(b *testing.B) {
+	benchmarkParallel(b, 1)
+}
@@ -0,0 +1,87 @@
+package service_test
+
+import (
+	"github.com/Adetunjii/go-grpc/service"
+	"github.com/Adetunjii/go-grpc/service/storetest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLaptopStoreConformance(t *testing.T) {
+	t.Parallel()
+
+	stores := []struct {
+		name     string
+		newStore storetest.LaptopStoreFactory
+	}{
+		{
+			name: "in_memory",
+			newStore: func(t *testing.T) service.LaptopStore {
+				return service.NewInMemoryLaptopStore()
+			},
+		},
+		{
+			name: "sharded",
+			newStore: func(t *testing.T) service.LaptopStore {
+				return service.NewShardedLaptopStore(4)
+			},
+		},
+		{
+			name: "file",
+			newStore: func(t *testing.T) service.LaptopStore {
+				// a small interval to snapshot while the tests run
+				store, err := service.NewFileLaptopStore(t.TempDir(), 5)
+				require.NoError(t, err)
+				t.Cleanup(func() { store.Close() })
+				return store
+			},
+		},
+		{
+			name: "cached",
+			newStore: func(t *testing.T) service.LaptopStore {
+				// a small capacity to evict while the tests run
+				return service.NewCachedLaptopStore(service.NewInMemoryLaptopStore(), 4)
+			},
+		},
+	}
+
+	for _, tc := range stores {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			storetest.RunLaptopStoreTests(t, tc.newStore)
+		})
+	}
+}
+
+func TestUserStoreConformance(t *testing.T) {
+	t.Parallel()
+
+	storetest.RunUserStoreTests(t, func(t *testing.T) service.UserStore {
+		return service.NewInMemoryUserStore()
+	})
+}
+
+func TestImageStoreConformance(t *testing.T) {
+	t.Parallel()
+
+	storetest.RunImageStoreTests(t, func(t *testing.T) service.ImageStore {
+		return service.NewDiskImageStore(t.TempDir())
+	})
+}
+
+func TestRatingStoreConformance(t *testing.T) {
+	t.Parallel()
+
+	storetest.RunRatingStoreTests(t, func(t *testing.T) service.RatingStore {
+		return service.NewInMemoryRatingStore()
+	})
+}
+
+func TestSavedSearchStoreConformance(t *testing.T) {
+	t.Parallel()
+
+	storetest.RunSavedSearchStoreTests(t, func(t *testing.T) service.SavedSearchStore {
+		return service.NewInMemorySavedSearchStore()
+	})
+}
@@ -0,0 +1,253 @@
+package storetest
+
+import (
+	"bytes"
+	"github.com/Adetunjii/go-grpc/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// ImageStoreFactory returns a new empty store, resources it holds can be released with t.Cleanup.
+// Stores keeping images on disk should use a folder of their own such as t.TempDir().
+type ImageStoreFactory func(t *testing.T) service.ImageStore
+
+// RunImageStoreTests runs the ImageStore conformance tests on the stores made by the factory
+func RunImageStoreTests(t *testing.T, newStore ImageStoreFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store service.ImageStore)
+	}{
+		{"SaveAndFind", testSaveAndFindImages},
+		{"FindMissing", testFindMissingImages},
+		{"DeleteByLaptopID", testDeleteImages},
+		{"DeleteAllExcept", testDeleteAllExceptImages},
+		{"Import", testImportImage},
+		{"ImportInvalid", testImportInvalidImage},
+		{"CopyIsolation", testImageCopyIsolation},
+		{"Concurrency", testImageConcurrency},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tc.test(t, newStore(t))
+		})
+	}
+}
+
+func saveImage(t *testing.T, store service.ImageStore, laptopID string, data string) string {
+	imageID, err := store.Save(laptopID, ".jpg", *bytes.NewBufferString(data))
+	require.NoError(t, err)
+	require.NotEmpty(t, imageID)
+	return imageID
+}
+
+func findImages(t *testing.T, store service.ImageStore, laptopID string) []*service.ImageInfo {
+	images, err := store.FindByLaptopID(laptopID)
+	require.NoError(t, err)
+	return images
+}
+
+// importPath returns a path inside the folder the store saves its images in
+func importPath(t *testing.T, store service.ImageStore, name string) string {
+	laptopID := uuid.New().String()
+	saveImage(t, store, laptopID, "image")
+	return filepath.Join(filepath.Dir(findImages(t, store, laptopID)[0].Path), name)
+}
+
+func imageIds(images []*service.ImageInfo) []string {
+	ids := make([]string, len(images))
+	for i, image := range images {
+		ids[i] = image.ID
+	}
+	return ids
+}
+
+func testSaveAndFindImages(t *testing.T, store service.ImageStore) {
+	laptopID := uuid.New().String()
+	ids := []string{
+		saveImage(t, store, laptopID, "first image"),
+		saveImage(t, store, laptopID, "second image"),
+	}
+	require.NotEqual(t, ids[0], ids[1], "image ids must be unique")
+	saveImage(t, store, uuid.New().String(), "image of another laptop")
+
+	images := findImages(t, store, laptopID)
+	sort.Strings(ids)
+	require.Equal(t, ids, imageIds(images), "images must be listed by id")
+
+	for _, image := range images {
+		require.Equal(t, laptopID, image.LaptopID)
+		require.Equal(t, ".jpg", image.Type)
+
+		data, err := os.ReadFile(image.Path)
+		require.NoError(t, err, "the image data must be at its path")
+		require.Contains(t, []string{"first image", "second image"}, string(data))
+	}
+}
+
+func testFindMissingImages(t *testing.T, store service.ImageStore) {
+	images, err := store.FindByLaptopID(uuid.New().String())
+	require.NoError(t, err, "a laptop without images is not an error")
+	require.Empty(t, images)
+}
+
+func testDeleteImages(t *testing.T, store service.ImageStore) {
+	laptopID := uuid.New().String()
+	otherID := uuid.New().String()
+	saveImage(t, store, laptopID, "first image")
+	saveImage(t, store, laptopID, "second image")
+	saveImage(t, store, otherID, "image of another laptop")
+
+	images := findImages(t, store, laptopID)
+	require.NoError(t, store.DeleteByLaptopID(laptopID))
+	require.Empty(t, findImages(t, store, laptopID))
+	require.Len(t, findImages(t, store, otherID), 1, "the images of other laptops must be kept")
+
+	for _, image := range images {
+		_, err := os.Stat(image.Path)
+		require.True(t, os.IsNotExist(err), "the data of a deleted image must be removed")
+	}
+
+	require.NoError(t, store.DeleteByLaptopID(laptopID), "deleting again is not an error")
+}
+
+func testDeleteAllExceptImages(t *testing.T, store service.ImageStore) {
+	laptopID := uuid.New().String()
+	otherID := uuid.New().String()
+
+	keptID := saveImage(t, store, laptopID, "kept")
+	saveImage(t, store, laptopID, "removed")
+	saveImage(t, store, otherID, "removed")
+	sharedID := saveImage(t, store, otherID, "shared")
+
+	images := append(findImages(t, store, laptopID), findImages(t, store, otherID)...)
+
+	// an image imported with the path of another one keeps its data when the other is deleted
+	copied := service.ImageInfo{}
+	for _, image := range images {
+		if image.ID == sharedID {
+			copied = *image
+		}
+	}
+	copied.ID = uuid.New().String()
+	require.NoError(t, store.Import(&copied))
+
+	require.NoError(t, store.DeleteAllExcept([]string{keptID, copied.ID}))
+
+	require.Equal(t, []string{keptID}, imageIds(findImages(t, store, laptopID)))
+	require.Equal(t, []string{copied.ID}, imageIds(findImages(t, store, otherID)))
+
+	for _, image := range images {
+		_, err := os.Stat(image.Path)
+		if image.ID == keptID || image.ID == sharedID {
+			require.NoError(t, err, "the data of a kept image must not be removed")
+		} else {
+			require.True(t, os.IsNotExist(err), "the data of a deleted image must be removed")
+		}
+	}
+}
+
+func testImportImage(t *testing.T, store service.ImageStore) {
+	laptopID := uuid.New().String()
+	image := &service.ImageInfo{
+		ID:       uuid.New().String(),
+		LaptopID: laptopID,
+		Type:     ".png",
+		Path:     importPath(t, store, "imported.png"),
+	}
+
+	require.NoError(t, store.Import(image))
+	require.Equal(t, []*service.ImageInfo{image}, findImages(t, store, laptopID))
+
+	// importing the same id again replaces the image, even when it moves to another laptop
+	moved := *image
+	moved.LaptopID = uuid.New().String()
+	require.NoError(t, store.Import(&moved))
+	require.Empty(t, findImages(t, store, laptopID))
+	require.Equal(t, []*service.ImageInfo{&moved}, findImages(t, store, moved.LaptopID))
+}
+
+func testImportInvalidImage(t *testing.T, store service.ImageStore) {
+	laptopID := uuid.New().String()
+	valid := &service.ImageInfo{
+		ID:       uuid.New().String(),
+		LaptopID: laptopID,
+		Type:     ".png",
+		Path:     importPath(t, store, "imported.png"),
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		path string
+	}{
+		{"id_not_uuid", "image-1", valid.Path},
+		{"absolute_path_outside", uuid.New().String(), "/etc/passwd"},
+		{"relative_path_outside", uuid.New().String(), importPath(t, store, "../outside.png")},
+		{"image_folder", uuid.New().String(), importPath(t, store, ".")},
+	}
+
+	for _, tc := range tests {
+		invalid := *valid
+		invalid.ID = tc.id
+		invalid.Path = tc.path
+
+		// the valid image is not recorded either
+		err := store.Import(valid, &invalid)
+		require.ErrorIs(t, err, service.InvalidImageException, tc.name)
+		require.Empty(t, findImages(t, store, laptopID), tc.name)
+	}
+}
+
+func testImageCopyIsolation(t *testing.T, store service.ImageStore) {
+	laptopID := uuid.New().String()
+	image := &service.ImageInfo{
+		ID:       uuid.New().String(),
+		LaptopID: laptopID,
+		Type:     ".png",
+		Path:     importPath(t, store, "imported.png"),
+	}
+	path := image.Path
+
+	require.NoError(t, store.Import(image))
+	image.Path = "changed after importing"
+	require.Equal(t, path, findImages(t, store, laptopID)[0].Path, "the store shares the imported image")
+
+	findImages(t, store, laptopID)[0].Path = "changed after reading"
+	require.Equal(t, path, findImages(t, store, laptopID)[0].Path, "FindByLaptopID shares the stored image")
+}
+
+func testImageConcurrency(t *testing.T, store service.ImageStore) {
+	const writers = 8
+
+	laptopID := uuid.New().String()
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+
+		// assert rather than require, FailNow must be called from the test goroutine
+		go func() {
+			defer wg.Done()
+			_, err := store.Save(laptopID, ".jpg", *bytes.NewBufferString("image"))
+			assert.NoError(t, err)
+		}()
+
+		go func() {
+			defer wg.Done()
+			_, err := store.FindByLaptopID(laptopID)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, findImages(t, store, laptopID), writers)
+}
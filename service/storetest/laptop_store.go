@@ -0,0 +1,548 @@
+// Package storetest checks that an implementation of a store interface of the service package
+// honours the contract the server relies on. A backend runs the suite from its own tests:
+//
+//	func TestMyLaptopStore(t *testing.T) {
+//		storetest.RunLaptopStoreTests(t, func(t *testing.T) service.LaptopStore {
+//			return NewMyLaptopStore()
+//		})
+//	}
+//
+// Every test gets a new empty store from the factory and runs in parallel with the others,
+// the suite is meant to be run with -race.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/sample"
+	"github.com/Adetunjii/go-grpc/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// LaptopStoreFactory returns a new empty store, resources it holds can be released with t.Cleanup
+type LaptopStoreFactory func(t *testing.T) service.LaptopStore
+
+// RunLaptopStoreTests runs the LaptopStore conformance tests on the stores made by the factory
+func RunLaptopStoreTests(t *testing.T, newStore LaptopStoreFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store service.LaptopStore)
+	}{
+		{"SaveAndFind", testSaveAndFind},
+		{"SaveDuplicate", testSaveDuplicate},
+		{"FindMissing", testFindMissing},
+		{"Update", testUpdate},
+		{"UpdateVersionMismatch", testUpdateVersionMismatch},
+		{"DeleteAndRestore", testDeleteAndRestore},
+		{"Purge", testPurge},
+		{"Search", testSearch},
+		{"SearchCancelled", testSearchCancelled},
+		{"SearchStopsOnError", testSearchStopsOnError},
+		{"SearchText", testSearchText},
+		{"List", testList},
+		{"Watch", testWatch},
+		{"CopyIsolation", testCopyIsolation},
+		{"Concurrency", testConcurrency},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tc.test(t, newStore(t))
+		})
+	}
+}
+
+func newLaptop(price float64) *pb.Laptop {
+	laptop := sample.NewLaptop()
+	laptop.PriceUsd = price
+	return laptop
+}
+
+func saveLaptops(t *testing.T, store service.LaptopStore, prices ...float64) []*pb.Laptop {
+	laptops := make([]*pb.Laptop, len(prices))
+	for i, price := range prices {
+		laptops[i] = newLaptop(price)
+		require.NoError(t, store.Save(laptops[i]))
+	}
+
+	return laptops
+}
+
+func findLaptop(t *testing.T, store service.LaptopStore, id string) *pb.Laptop {
+	laptop, err := store.FindById(id)
+	require.NoError(t, err)
+	return laptop
+}
+
+func searchIds(t *testing.T, store service.LaptopStore, filter *pb.Filter) []string {
+	ids := []string{}
+	err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+		ids = append(ids, laptop.GetId())
+		return nil
+	})
+	require.NoError(t, err)
+
+	sort.Strings(ids)
+	return ids
+}
+
+func sortedIds(laptops ...*pb.Laptop) []string {
+	ids := make([]string, len(laptops))
+	for i, laptop := range laptops {
+		ids[i] = laptop.GetId()
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+func priceMask() *fieldmaskpb.FieldMask {
+	return &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}
+}
+
+func testSaveAndFind(t *testing.T, store service.LaptopStore) {
+	laptop := newLaptop(1500)
+	require.NoError(t, store.Save(laptop))
+
+	found := findLaptop(t, store, laptop.Id)
+	require.NotNil(t, found)
+	require.EqualValues(t, 1, found.GetVersion(), "a saved laptop starts at version 1")
+
+	found.Version = laptop.Version
+	require.True(t, proto.Equal(laptop, found), "the found laptop differs from the saved one")
+}
+
+func testSaveDuplicate(t *testing.T, store service.LaptopStore) {
+	laptop := newLaptop(1500)
+	require.NoError(t, store.Save(laptop))
+
+	other := newLaptop(2000)
+	other.Id = laptop.Id
+	err := store.Save(other)
+	require.True(t, errors.Is(err, service.DuplicateException), "expected DuplicateException, got %v", err)
+
+	require.Equal(t, float64(1500), findLaptop(t, store, laptop.Id).GetPriceUsd())
+
+	// a laptop in the trash still holds its id
+	require.NoError(t, store.Delete(laptop.Id, 0))
+	err = store.Save(other)
+	require.True(t, errors.Is(err, service.DuplicateException), "expected DuplicateException, got %v", err)
+}
+
+func testFindMissing(t *testing.T, store service.LaptopStore) {
+	laptop, err := store.FindById(sample.NewLaptop().Id)
+	require.NoError(t, err, "a missing laptop is not an error")
+	require.Nil(t, laptop)
+}
+
+func testUpdate(t *testing.T, store service.LaptopStore) {
+	laptop := saveLaptops(t, store, 1500)[0]
+
+	changes := &pb.Laptop{Id: laptop.Id, PriceUsd: 1200, Brand: "ignored"}
+	updated, err := store.Update(changes, priceMask(), 1)
+	require.NoError(t, err)
+	require.Equal(t, float64(1200), updated.GetPriceUsd())
+	require.Equal(t, laptop.Brand, updated.GetBrand(), "fields outside of the mask must not change")
+	require.EqualValues(t, 2, updated.GetVersion())
+
+	found := findLaptop(t, store, laptop.Id)
+	require.True(t, proto.Equal(updated, found), "the found laptop differs from the updated one")
+
+	_, err = store.Update(&pb.Laptop{Id: sample.NewLaptop().Id}, priceMask(), 0)
+	require.True(t, errors.Is(err, service.NotFoundException), "expected NotFoundException, got %v", err)
+}
+
+func testUpdateVersionMismatch(t *testing.T, store service.LaptopStore) {
+	laptop := saveLaptops(t, store, 1500)[0]
+
+	_, err := store.Update(&pb.Laptop{Id: laptop.Id, PriceUsd: 1200}, priceMask(), 2)
+	require.True(t, errors.Is(err, service.VersionMismatchException), "expected VersionMismatchException, got %v", err)
+
+	found := findLaptop(t, store, laptop.Id)
+	require.Equal(t, float64(1500), found.GetPriceUsd())
+	require.EqualValues(t, 1, found.GetVersion())
+
+	err = store.Delete(laptop.Id, 2)
+	require.True(t, errors.Is(err, service.VersionMismatchException), "expected VersionMismatchException, got %v", err)
+}
+
+func testDeleteAndRestore(t *testing.T, store service.LaptopStore) {
+	laptops := saveLaptops(t, store, 1500, 2000)
+
+	require.NoError(t, store.Delete(laptops[0].Id, 1))
+	require.Nil(t, findLaptop(t, store, laptops[0].Id), "a deleted laptop must be hidden")
+	require.Equal(t, sortedIds(laptops[1]), searchIds(t, store, nil))
+
+	listed, err := store.List(context.Background(), "", 10)
+	require.NoError(t, err)
+	require.Equal(t, sortedIds(laptops[1]), sortedIds(listed...))
+
+	deleted := []*pb.Laptop{}
+	err = store.ListDeleted(context.Background(), func(laptop *pb.Laptop) error {
+		deleted = append(deleted, laptop)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.Equal(t, laptops[0].Id, deleted[0].GetId())
+	require.NotNil(t, deleted[0].GetDeletedAt())
+	require.EqualValues(t, 2, deleted[0].GetVersion())
+
+	err = store.Delete(laptops[0].Id, 0)
+	require.True(t, errors.Is(err, service.NotFoundException), "deleting twice: expected NotFoundException, got %v", err)
+
+	_, err = store.Update(&pb.Laptop{Id: laptops[0].Id}, priceMask(), 0)
+	require.True(t, errors.Is(err, service.NotFoundException), "updating in the trash: expected NotFoundException, got %v", err)
+
+	_, err = store.Restore(laptops[0].Id, 1)
+	require.True(t, errors.Is(err, service.VersionMismatchException), "expected VersionMismatchException, got %v", err)
+
+	restored, err := store.Restore(laptops[0].Id, 2)
+	require.NoError(t, err)
+	require.Nil(t, restored.GetDeletedAt())
+	require.EqualValues(t, 3, restored.GetVersion())
+	require.NotNil(t, findLaptop(t, store, laptops[0].Id))
+
+	_, err = store.Restore(laptops[0].Id, 0)
+	require.True(t, errors.Is(err, service.NotFoundException), "restoring a laptop not in the trash: expected NotFoundException, got %v", err)
+
+	_, err = store.Restore(sample.NewLaptop().Id, 0)
+	require.True(t, errors.Is(err, service.NotFoundException), "restoring a missing laptop: expected NotFoundException, got %v", err)
+}
+
+func testPurge(t *testing.T, store service.LaptopStore) {
+	laptops := saveLaptops(t, store, 1500, 2000, 2500)
+
+	require.NoError(t, store.Delete(laptops[0].Id, 0))
+	require.NoError(t, store.Delete(laptops[1].Id, 0))
+
+	purged, err := store.Purge(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, purged, "laptops deleted after the given time must be kept")
+
+	purged, err = store.Purge(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	sort.Strings(purged)
+	require.Equal(t, sortedIds(laptops[0], laptops[1]), purged)
+
+	_, err = store.Restore(laptops[0].Id, 0)
+	require.True(t, errors.Is(err, service.NotFoundException), "restoring a purged laptop: expected NotFoundException, got %v", err)
+
+	// the id of a purged laptop can be used again
+	require.NoError(t, store.Save(laptops[0]))
+	require.Equal(t, sortedIds(laptops[0], laptops[2]), searchIds(t, store, nil))
+}
+
+func testSearch(t *testing.T, store service.LaptopStore) {
+	laptops := saveLaptops(t, store, 1000, 1500, 2000, 2500)
+
+	require.Equal(t, sortedIds(laptops...), searchIds(t, store, nil), "a nil filter matches every laptop")
+	require.Equal(t, sortedIds(laptops[0], laptops[1]), searchIds(t, store, &pb.Filter{MaxPriceUsd: 1500}))
+	require.Empty(t, searchIds(t, store, &pb.Filter{MaxPriceUsd: 500}))
+
+	_, err := store.Update(&pb.Laptop{Id: laptops[3].Id, PriceUsd: 900}, priceMask(), 0)
+	require.NoError(t, err)
+	require.Equal(t, sortedIds(laptops[0], laptops[1], laptops[3]), searchIds(t, store, &pb.Filter{MaxPriceUsd: 1500}))
+}
+
+func testSearchCancelled(t *testing.T, store service.LaptopStore) {
+	saveLaptops(t, store, 1000, 1500, 2000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	found := 0
+	err := store.Search(ctx, nil, func(laptop *pb.Laptop) error {
+		found++
+		return nil
+	})
+	require.Error(t, err, "searching with a cancelled context must fail")
+	require.Zero(t, found, "no laptop must be found with a cancelled context")
+
+	err = store.SearchText(ctx, "macbook", nil, func(laptop *pb.Laptop, relevance float64) error {
+		found++
+		return nil
+	})
+	require.Error(t, err, "searching text with a cancelled context must fail")
+	require.Zero(t, found, "no laptop must be found with a cancelled context")
+
+	_, err = store.List(ctx, "", 10)
+	require.Error(t, err, "listing with a cancelled context must fail")
+}
+
+func testSearchStopsOnError(t *testing.T, store service.LaptopStore) {
+	saveLaptops(t, store, 1000, 1500, 2000)
+
+	stop := errors.New("stop")
+	found := 0
+	err := store.Search(context.Background(), nil, func(laptop *pb.Laptop) error {
+		found++
+		return stop
+	})
+	require.True(t, errors.Is(err, stop), "the error of found must be returned, got %v", err)
+	require.Equal(t, 1, found, "the search must stop at the first error")
+}
+
+func testSearchText(t *testing.T, store service.LaptopStore) {
+	laptops := saveLaptops(t, store, 1000, 1500, 2000)
+	laptops[0].Name = "ThinkPad X1"
+	laptops[0].Brand = "Lenovo"
+	_, err := store.Update(laptops[0], &fieldmaskpb.FieldMask{Paths: []string{"name", "brand"}}, 0)
+	require.NoError(t, err)
+
+	search := func(query string, filter *pb.Filter) map[string]float64 {
+		relevances := make(map[string]float64)
+		err := store.SearchText(context.Background(), query, filter, func(laptop *pb.Laptop, relevance float64) error {
+			relevances[laptop.GetId()] = relevance
+			return nil
+		})
+		require.NoError(t, err)
+		return relevances
+	}
+
+	matches := search("lenovo think", nil)
+	require.Len(t, matches, 1, "every word must match, possibly as a prefix")
+	require.Greater(t, matches[laptops[0].Id], 0.0)
+
+	require.Len(t, search("MACBOOK", nil), 2, "matching ignores case")
+	require.Len(t, search("macbook", &pb.Filter{MaxPriceUsd: 1500}), 1, "the filter applies")
+
+	matches = search("", nil)
+	require.Len(t, matches, 3, "a query without any word matches every laptop")
+	for _, relevance := range matches {
+		require.Zero(t, relevance)
+	}
+}
+
+func testList(t *testing.T, store service.LaptopStore) {
+	laptops := saveLaptops(t, store, 1000, 1100, 1200, 1300, 1400, 1500, 1600)
+	require.NoError(t, store.Delete(laptops[3].Id, 0))
+
+	ids := []string{}
+	afterId := ""
+	for {
+		page, err := store.List(context.Background(), afterId, 2)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page), 2)
+
+		if len(page) == 0 {
+			break
+		}
+
+		for _, laptop := range page {
+			ids = append(ids, laptop.GetId())
+		}
+		afterId = page[len(page)-1].GetId()
+	}
+
+	require.True(t, sort.StringsAreSorted(ids), "laptops must be listed by id")
+	expected := append(append([]*pb.Laptop{}, laptops[:3]...), laptops[4:]...)
+	require.Equal(t, sortedIds(expected...), ids)
+}
+
+func testWatch(t *testing.T, store service.LaptopStore) {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := store.Watch(ctx)
+
+	laptop := saveLaptops(t, store, 1000)[0]
+	_, err := store.Update(&pb.Laptop{Id: laptop.Id, PriceUsd: 1200}, priceMask(), 0)
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(laptop.Id, 0))
+	_, err = store.Restore(laptop.Id, 0)
+	require.NoError(t, err)
+
+	expected := []pb.LaptopEvent_Type{
+		pb.LaptopEvent_CREATED,
+		pb.LaptopEvent_UPDATED,
+		pb.LaptopEvent_DELETED,
+		pb.LaptopEvent_RESTORED,
+	}
+
+	for i, eventType := range expected {
+		select {
+		case event := <-events:
+			require.Equal(t, eventType, event.GetType())
+			require.Equal(t, laptop.Id, event.GetLaptop().GetId())
+			require.EqualValues(t, i+1, event.GetLaptop().GetVersion())
+		case <-time.After(time.Second):
+			require.Failf(t, "missing event", "expected a %s event", eventType)
+		}
+	}
+
+	// the channel is closed once the context is done
+	cancel()
+	select {
+	case _, ok := <-events:
+		require.False(t, ok, "no event was expected")
+	case <-time.After(time.Second):
+		require.Fail(t, "the channel must be closed when the context is done")
+	}
+}
+
+// testCopyIsolation checks that neither the laptops given to the store nor the ones it returns share state with it
+func testCopyIsolation(t *testing.T, store service.LaptopStore) {
+	laptop := newLaptop(1000)
+	require.NoError(t, store.Save(laptop))
+	id := laptop.Id
+
+	laptop.PriceUsd = 1
+	laptop.Cpu.Name = "changed after saving"
+
+	mutate := func(laptop *pb.Laptop) {
+		laptop.PriceUsd = 2
+		laptop.Cpu.Name = "changed after reading"
+		laptop.Gpus[0].Name = "changed after reading"
+		laptop.Version = 100
+	}
+
+	check := func(what string) {
+		stored := findLaptop(t, store, id)
+		require.NotEqual(t, float64(1), stored.GetPriceUsd(), "the store shares the saved laptop")
+		require.NotEqual(t, float64(2), stored.GetPriceUsd(), "%s shares the stored laptop", what)
+		require.NotEqual(t, "changed after reading", stored.GetCpu().GetName(), "%s shares the stored cpu", what)
+		require.NotEqual(t, "changed after reading", stored.GetGpus()[0].GetName(), "%s shares the stored gpus", what)
+		require.NotEqual(t, uint64(100), stored.GetVersion(), "%s shares the stored laptop", what)
+	}
+
+	check("Save")
+
+	mutate(findLaptop(t, store, id))
+	check("FindById")
+
+	err := store.Search(context.Background(), nil, func(laptop *pb.Laptop) error {
+		mutate(laptop)
+		return nil
+	})
+	require.NoError(t, err)
+	check("Search")
+
+	err = store.SearchText(context.Background(), "macbook", nil, func(laptop *pb.Laptop, relevance float64) error {
+		mutate(laptop)
+		return nil
+	})
+	require.NoError(t, err)
+	check("SearchText")
+
+	listed, err := store.List(context.Background(), "", 10)
+	require.NoError(t, err)
+	mutate(listed[0])
+	check("List")
+
+	changes := &pb.Laptop{Id: id, PriceUsd: 1500}
+	updated, err := store.Update(changes, priceMask(), 0)
+	require.NoError(t, err)
+	changes.PriceUsd = 1
+	check("the changes given to Update")
+	mutate(updated)
+	check("Update")
+
+	events := store.Watch(context.Background())
+	require.NoError(t, store.Delete(id, 0))
+
+	err = store.ListDeleted(context.Background(), func(laptop *pb.Laptop) error {
+		mutate(laptop)
+		return nil
+	})
+	require.NoError(t, err)
+
+	restored, err := store.Restore(id, 0)
+	require.NoError(t, err)
+	mutate(restored)
+	check("ListDeleted and Restore")
+
+	for i := 0; i < 2; i++ {
+		mutate((<-events).GetLaptop())
+	}
+	check("Watch")
+}
+
+func testConcurrency(t *testing.T, store service.LaptopStore) {
+	const writers = 8
+	const updates = 10
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := store.Watch(ctx)
+
+	// watchers may be dropped when falling behind, this one keeps up as long as it can
+	go func() {
+		for range events {
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	laptops := make([]*pb.Laptop, writers)
+
+	for i := 0; i < writers; i++ {
+		laptops[i] = newLaptop(1000)
+		wg.Add(3)
+
+		// assert rather than require, FailNow must be called from the test goroutine
+		go func(laptop *pb.Laptop, deleted bool) {
+			defer wg.Done()
+
+			if !assert.NoError(t, store.Save(laptop)) {
+				return
+			}
+			for j := 0; j < updates; j++ {
+				changes := &pb.Laptop{Id: laptop.Id, PriceUsd: float64(1001 + j)}
+				_, err := store.Update(changes, priceMask(), 0)
+				assert.NoError(t, err)
+			}
+
+			if deleted {
+				assert.NoError(t, store.Delete(laptop.Id, 0))
+			}
+		}(laptops[i], i%2 == 0)
+
+		go func(laptop *pb.Laptop) {
+			defer wg.Done()
+
+			for j := 0; j < updates; j++ {
+				_, err := store.FindById(laptop.Id)
+				assert.NoError(t, err)
+			}
+		}(laptops[i])
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < updates; j++ {
+				err := store.Search(context.Background(), &pb.Filter{MaxPriceUsd: 1005}, func(laptop *pb.Laptop) error {
+					return nil
+				})
+				assert.NoError(t, err)
+
+				_, err = store.List(context.Background(), "", writers)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	remaining := []*pb.Laptop{}
+	for i, laptop := range laptops {
+		if i%2 == 0 {
+			require.Nil(t, findLaptop(t, store, laptop.Id))
+			continue
+		}
+
+		found := findLaptop(t, store, laptop.Id)
+		require.NotNil(t, found)
+		require.EqualValues(t, 1+updates, found.GetVersion(), "an update was lost")
+		require.Equal(t, float64(1000+updates), found.GetPriceUsd())
+		remaining = append(remaining, laptop)
+	}
+
+	require.Equal(t, sortedIds(remaining...), searchIds(t, store, nil))
+}
@@ -0,0 +1,93 @@
+package storetest
+
+import (
+	"github.com/Adetunjii/go-grpc/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+// RatingStoreFactory returns a new empty store, resources it holds can be released with t.Cleanup
+type RatingStoreFactory func(t *testing.T) service.RatingStore
+
+// RunRatingStoreTests runs the RatingStore conformance tests on the stores made by the factory
+func RunRatingStoreTests(t *testing.T, newStore RatingStoreFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store service.RatingStore)
+	}{
+		{"Add", testAddRating},
+		{"CopyIsolation", testRatingCopyIsolation},
+		{"Concurrency", testRatingConcurrency},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tc.test(t, newStore(t))
+		})
+	}
+}
+
+func addRating(t *testing.T, store service.RatingStore, laptopID string, score float64) *service.Rating {
+	rating, err := store.Add(laptopID, score)
+	require.NoError(t, err)
+	require.NotNil(t, rating)
+	return rating
+}
+
+func testAddRating(t *testing.T, store service.RatingStore) {
+	laptopID := uuid.New().String()
+	require.Equal(t, &service.Rating{Count: 1, Sum: 5}, addRating(t, store, laptopID, 5))
+	require.Equal(t, &service.Rating{Count: 2, Sum: 8}, addRating(t, store, laptopID, 3))
+
+	require.Equal(t, &service.Rating{Count: 1, Sum: 4}, addRating(t, store, uuid.New().String(), 4),
+		"every laptop must be rated on its own")
+}
+
+func testRatingCopyIsolation(t *testing.T, store service.RatingStore) {
+	laptopID := uuid.New().String()
+
+	rating := addRating(t, store, laptopID, 5)
+	rating.Count = 100
+	rating.Sum = 100
+
+	require.Equal(t, &service.Rating{Count: 2, Sum: 10}, addRating(t, store, laptopID, 5), "Add shares the stored rating")
+}
+
+func testRatingConcurrency(t *testing.T, store service.RatingStore) {
+	const (
+		writers         = 8
+		scoresPerWriter = 50
+	)
+
+	laptopID := uuid.New().String()
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+
+		// assert rather than require, FailNow must be called from the test goroutine
+		go func() {
+			defer wg.Done()
+
+			previous := uint32(0)
+			for j := 0; j < scoresPerWriter; j++ {
+				rating, err := store.Add(laptopID, 1)
+				if !assert.NoError(t, err) || !assert.NotNil(t, rating) {
+					return
+				}
+				assert.Greater(t, rating.Count, previous, "the count of a laptop must only grow")
+				assert.Equal(t, float64(rating.Count), rating.Sum, "the rating must count every score it sums")
+				previous = rating.Count
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, &service.Rating{Count: writers*scoresPerWriter + 1, Sum: writers*scoresPerWriter + 1},
+		addRating(t, store, laptopID, 1), "no score may be lost")
+}
@@ -0,0 +1,163 @@
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"sync"
+	"testing"
+	"time"
+)
+
+// SavedSearchStoreFactory returns a new empty store, resources it holds can be released with t.Cleanup
+type SavedSearchStoreFactory func(t *testing.T) service.SavedSearchStore
+
+// RunSavedSearchStoreTests runs the SavedSearchStore conformance tests on the stores made by the factory
+func RunSavedSearchStoreTests(t *testing.T, newStore SavedSearchStoreFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store service.SavedSearchStore)
+	}{
+		{"SaveAndList", testSaveAndListSearches},
+		{"SaveDuplicate", testSaveDuplicateSearch},
+		{"ListMissing", testListMissingSearches},
+		{"Delete", testDeleteSearch},
+		{"CopyIsolation", testSearchCopyIsolation},
+		{"Concurrency", testSearchConcurrency},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tc.test(t, newStore(t))
+		})
+	}
+}
+
+func newSavedSearch(name string, createdAt time.Time) *pb.SavedSearch {
+	return &pb.SavedSearch{
+		Id:        uuid.New().String(),
+		Name:      name,
+		Filter:    &pb.Filter{MaxPriceUsd: 3000},
+		CreatedAt: timestamppb.New(createdAt),
+	}
+}
+
+func listSearches(t *testing.T, store service.SavedSearchStore, username string) []*pb.SavedSearch {
+	searches, err := store.List(username)
+	require.NoError(t, err)
+	return searches
+}
+
+func requireSearches(t *testing.T, expected []*pb.SavedSearch, actual []*pb.SavedSearch, msgAndArgs ...interface{}) {
+	require.Len(t, actual, len(expected), msgAndArgs...)
+	for i := range expected {
+		require.True(t, proto.Equal(expected[i], actual[i]), msgAndArgs...)
+	}
+}
+
+func testSaveAndListSearches(t *testing.T, store service.SavedSearchStore) {
+	now := time.Now().Truncate(time.Second)
+	newer := newSavedSearch("newer", now)
+	older := newSavedSearch("older", now.Add(-time.Hour))
+
+	require.NoError(t, store.Save("alice", newer))
+	require.NoError(t, store.Save("alice", older))
+	require.NoError(t, store.Save("bob", newSavedSearch("other user", now)))
+
+	requireSearches(t, []*pb.SavedSearch{older, newer}, listSearches(t, store, "alice"), "searches must be listed oldest first")
+}
+
+func testSaveDuplicateSearch(t *testing.T, store service.SavedSearchStore) {
+	search := newSavedSearch("first", time.Now())
+	require.NoError(t, store.Save("alice", search))
+
+	other := proto.Clone(search).(*pb.SavedSearch)
+	other.Name = "second"
+	err := store.Save("alice", other)
+	require.True(t, errors.Is(err, service.DuplicateException), "expected DuplicateException, got %v", err)
+
+	err = store.Save("bob", other)
+	require.True(t, errors.Is(err, service.DuplicateException), "ids must be unique across users, got %v", err)
+
+	requireSearches(t, []*pb.SavedSearch{search}, listSearches(t, store, "alice"))
+	require.Empty(t, listSearches(t, store, "bob"))
+}
+
+func testListMissingSearches(t *testing.T, store service.SavedSearchStore) {
+	searches, err := store.List("nobody")
+	require.NoError(t, err, "a user without searches is not an error")
+	require.Empty(t, searches)
+}
+
+func testDeleteSearch(t *testing.T, store service.SavedSearchStore) {
+	search := newSavedSearch("search", time.Now())
+	require.NoError(t, store.Save("alice", search))
+
+	err := store.Delete("bob", search.Id)
+	require.True(t, errors.Is(err, service.NotFoundException), "another user must not delete the search, got %v", err)
+
+	require.NoError(t, store.Delete("alice", search.Id))
+	require.Empty(t, listSearches(t, store, "alice"))
+
+	err = store.Delete("alice", search.Id)
+	require.True(t, errors.Is(err, service.NotFoundException), "expected NotFoundException, got %v", err)
+}
+
+func testSearchCopyIsolation(t *testing.T, store service.SavedSearchStore) {
+	search := newSavedSearch("search", time.Now())
+	require.NoError(t, store.Save("alice", search))
+
+	search.Name = "changed after saving"
+	search.Filter.MaxPriceUsd = 1
+	stored := listSearches(t, store, "alice")[0]
+	require.Equal(t, "search", stored.Name, "the store shares the saved search")
+	require.Equal(t, float64(3000), stored.Filter.GetMaxPriceUsd(), "the store shares the filter of the saved search")
+
+	stored.Name = "changed after listing"
+	stored.Filter.MaxPriceUsd = 1
+	stored = listSearches(t, store, "alice")[0]
+	require.Equal(t, "search", stored.Name, "List shares the stored search")
+	require.Equal(t, float64(3000), stored.Filter.GetMaxPriceUsd(), "List shares the filter of the stored search")
+}
+
+func testSearchConcurrency(t *testing.T, store service.SavedSearchStore) {
+	const (
+		writers           = 8
+		searchesPerWriter = 20
+	)
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+
+		// assert rather than require, FailNow must be called from the test goroutine
+		go func(i int) {
+			defer wg.Done()
+
+			username := fmt.Sprintf("user-%d", i%2)
+			for j := 0; j < searchesPerWriter; j++ {
+				search := newSavedSearch(fmt.Sprintf("search-%d-%d", i, j), time.Now())
+				assert.NoError(t, store.Save(username, search))
+				_, err := store.List(username)
+				assert.NoError(t, err)
+
+				if j%2 == 1 {
+					assert.NoError(t, store.Delete(username, search.Id))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	total := len(listSearches(t, store, "user-0")) + len(listSearches(t, store, "user-1"))
+	require.Equal(t, writers*searchesPerWriter/2, total)
+}
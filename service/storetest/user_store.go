@@ -0,0 +1,127 @@
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Adetunjii/go-grpc/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+// UserStoreFactory returns a new empty store, resources it holds can be released with t.Cleanup
+type UserStoreFactory func(t *testing.T) service.UserStore
+
+// RunUserStoreTests runs the UserStore conformance tests on the stores made by the factory
+func RunUserStoreTests(t *testing.T, newStore UserStoreFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store service.UserStore)
+	}{
+		{"SaveAndFind", testSaveAndFindUser},
+		{"SaveDuplicate", testSaveDuplicateUser},
+		{"FindMissing", testFindMissingUser},
+		{"CopyIsolation", testUserCopyIsolation},
+		{"Concurrency", testUserConcurrency},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tc.test(t, newStore(t))
+		})
+	}
+}
+
+// newUser doesn't hash a real password, which would slow the tests down for nothing
+func newUser(username string, role string) *service.User {
+	return &service.User{
+		Username:       username,
+		HashedPassword: "hashed-" + username,
+		Role:           role,
+	}
+}
+
+func findUser(t *testing.T, store service.UserStore, username string) *service.User {
+	user, err := store.Find(username)
+	require.NoError(t, err)
+	return user
+}
+
+func testSaveAndFindUser(t *testing.T, store service.UserStore) {
+	user := newUser("alice", "admin")
+	require.NoError(t, store.Save(user))
+	require.Equal(t, user, findUser(t, store, "alice"))
+}
+
+func testSaveDuplicateUser(t *testing.T, store service.UserStore) {
+	require.NoError(t, store.Save(newUser("alice", "admin")))
+
+	err := store.Save(newUser("alice", "user"))
+	require.True(t, errors.Is(err, service.DuplicateException), "expected DuplicateException, got %v", err)
+	require.Equal(t, "admin", findUser(t, store, "alice").Role)
+}
+
+func testFindMissingUser(t *testing.T, store service.UserStore) {
+	user, err := store.Find("nobody")
+	require.NoError(t, err, "a missing user is not an error")
+	require.Nil(t, user)
+}
+
+func testUserCopyIsolation(t *testing.T, store service.UserStore) {
+	user := newUser("alice", "user")
+	require.NoError(t, store.Save(user))
+
+	user.Role = "admin"
+	require.Equal(t, "user", findUser(t, store, "alice").Role, "the store shares the saved user")
+
+	findUser(t, store, "alice").Role = "admin"
+	require.Equal(t, "user", findUser(t, store, "alice").Role, "Find shares the stored user")
+}
+
+func testUserConcurrency(t *testing.T, store service.UserStore) {
+	const writers = 8
+
+	wg := sync.WaitGroup{}
+	saved := make(chan bool, writers)
+
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+
+		// assert rather than require, FailNow must be called from the test goroutine
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, store.Save(newUser(fmt.Sprintf("user-%d", i), "user")))
+		}(i)
+
+		// every writer tries to take the same name, only one may succeed
+		go func(i int) {
+			defer wg.Done()
+
+			err := store.Save(newUser("shared", fmt.Sprintf("role-%d", i)))
+			if err != nil {
+				assert.True(t, errors.Is(err, service.DuplicateException), "expected DuplicateException, got %v", err)
+			}
+			saved <- err == nil
+
+			_, err = store.Find("shared")
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	close(saved)
+
+	count := 0
+	for ok := range saved {
+		if ok {
+			count++
+		}
+	}
+	require.Equal(t, 1, count, "a username must only be saved once")
+
+	for i := 0; i < writers; i++ {
+		require.NotNil(t, findUser(t, store, fmt.Sprintf("user-%d", i)))
+	}
+}
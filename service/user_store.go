@@ -1,12 +1,12 @@
 package service
 
-import (
-	"errors"
-	"sync"
-)
+import "sync"
 
 type UserStore interface {
+	// Save stores a copy of the user, DuplicateException is returned if the username is taken
 	Save(user *User) error
+
+	// Find returns a copy of the user, or nil if there is no user with that name
 	Find(username string) (*User, error)
 }
 
@@ -26,7 +26,7 @@ func (store *InMemoryUserStore) Save(user *User) error {
 	defer store.mutex.Unlock()
 
 	if store.users[user.Username] != nil {
-		return errors.New("already exists")
+		return DuplicateException
 	}
 
 	store.users[user.Username] = user.Clone()
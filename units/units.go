@@ -0,0 +1,45 @@
+// Package units converts the memory sizes and weights of laptops to the units searches compare them in,
+// so that filters, indexes and filter expressions can never disagree on a conversion.
+package units
+
+import "github.com/Adetunjii/go-grpc/pb"
+
+// KgPerLb is the number of kilograms in a pound
+const KgPerLb = 0.45359237
+
+// ToBit converts a memory size to bits, an unknown unit gives 0
+func ToBit(value uint64, unit pb.Memory_Unit) uint64 {
+	switch unit {
+	case pb.Memory_BIT:
+		return value
+	case pb.Memory_BYTE:
+		return value << 3
+	case pb.Memory_KILOBYTE:
+		return value << 13
+	case pb.Memory_MEGABYTE:
+		return value << 23
+	case pb.Memory_GIGABYTE:
+		return value << 33
+	case pb.Memory_TERABYTE:
+		return value << 43
+	default:
+		return 0
+	}
+}
+
+// MemoryBits converts the memory to bits, a nil memory gives 0
+func MemoryBits(memory *pb.Memory) uint64 {
+	return ToBit(memory.GetValue(), memory.GetUnit())
+}
+
+// WeightKg normalizes the laptop weight to kilograms, ok is false if the weight is not set
+func WeightKg(laptop *pb.Laptop) (float64, bool) {
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		return weight.WeightKg, true
+	case *pb.Laptop_WeightLb:
+		return weight.WeightLb * KgPerLb, true
+	default:
+		return 0, false
+	}
+}
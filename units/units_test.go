@@ -0,0 +1,49 @@
+package units
+
+import (
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMemoryBits(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		memory *pb.Memory
+		bits   uint64
+	}{
+		{"bit", &pb.Memory{Value: 3, Unit: pb.Memory_BIT}, 3},
+		{"byte", &pb.Memory{Value: 3, Unit: pb.Memory_BYTE}, 24},
+		{"kilobyte", &pb.Memory{Value: 1, Unit: pb.Memory_KILOBYTE}, 8 << 10},
+		{"megabyte", &pb.Memory{Value: 1, Unit: pb.Memory_MEGABYTE}, 8 << 20},
+		{"gigabyte", &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}, 16 * 8 << 30},
+		{"terabyte", &pb.Memory{Value: 2, Unit: pb.Memory_TERABYTE}, 2 * 8 << 40},
+		{"unknown_unit", &pb.Memory{Value: 2, Unit: pb.Memory_UNKNOWN}, 0},
+		{"nil", nil, 0},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.bits, MemoryBits(tc.memory))
+		})
+	}
+}
+
+func TestWeightKg(t *testing.T) {
+	t.Parallel()
+
+	weight, ok := WeightKg(&pb.Laptop{Weight: &pb.Laptop_WeightKg{WeightKg: 1.5}})
+	require.True(t, ok)
+	require.Equal(t, 1.5, weight)
+
+	weight, ok = WeightKg(&pb.Laptop{Weight: &pb.Laptop_WeightLb{WeightLb: 10}})
+	require.True(t, ok)
+	require.InDelta(t, 4.5359237, weight, 1e-9)
+
+	_, ok = WeightKg(&pb.Laptop{})
+	require.False(t, ok)
+}
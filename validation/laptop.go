@@ -0,0 +1,173 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/Adetunjii/go-grpc/pb"
+	"strings"
+)
+
+// FieldViolation describes a single invalid field of a message
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// Errors holds every violation found in a message
+type Errors []FieldViolation
+
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+	for i, violation := range errs {
+		messages[i] = fmt.Sprintf("%s: %s", violation.Field, violation.Description)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Fields returns the path of every offending field
+func (errs Errors) Fields() []string {
+	fields := make([]string, len(errs))
+	for i, violation := range errs {
+		fields[i] = violation.Field
+	}
+
+	return fields
+}
+
+type validator struct {
+	errs Errors
+}
+
+func (v *validator) add(field string, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldViolation{
+		Field:       field,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) check(ok bool, field string, format string, args ...interface{}) {
+	if !ok {
+		v.add(field, format, args...)
+	}
+}
+
+// ValidateLaptop checks every field of the laptop and returns all the violations at once as Errors,
+// or nil if the laptop is valid
+func ValidateLaptop(laptop *pb.Laptop) error {
+	v := &validator{}
+
+	if laptop == nil {
+		v.add("laptop", "is required")
+		return v.errs
+	}
+
+	v.check(strings.TrimSpace(laptop.GetBrand()) != "", "brand", "must not be empty")
+	v.check(strings.TrimSpace(laptop.GetName()) != "", "name", "must not be empty")
+	v.check(laptop.GetPriceUsd() >= 0, "price_usd", "must not be negative")
+
+	v.validateCPU("cpu", laptop.GetCpu())
+	v.validateMemory("ram", laptop.GetRam())
+
+	for i, gpu := range laptop.GetGpus() {
+		v.validateGPU(fmt.Sprintf("gpus[%d]", i), gpu)
+	}
+
+	v.check(len(laptop.GetStorages()) > 0, "storages", "must contain at least one storage")
+	for i, storage := range laptop.GetStorages() {
+		v.validateStorage(fmt.Sprintf("storages[%d]", i), storage)
+	}
+
+	v.validateScreen("screen", laptop.GetScreen())
+	v.validateKeyboard("keyboard", laptop.GetKeyboard())
+
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		v.check(weight.WeightKg > 0, "weight_kg", "must be positive")
+	case *pb.Laptop_WeightLb:
+		v.check(weight.WeightLb > 0, "weight_lb", "must be positive")
+	default:
+		v.add("weight", "one of weight_kg or weight_lb is required")
+	}
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+
+	return nil
+}
+
+func (v *validator) validateCPU(field string, cpu *pb.CPU) {
+	if cpu == nil {
+		v.add(field, "is required")
+		return
+	}
+
+	v.check(strings.TrimSpace(cpu.GetBrand()) != "", field+".brand", "must not be empty")
+	v.check(strings.TrimSpace(cpu.GetName()) != "", field+".name", "must not be empty")
+	v.check(cpu.GetNumberOfCores() > 0, field+".number_of_cores", "must be positive")
+	v.check(cpu.GetNumberOfThreads() >= cpu.GetNumberOfCores(), field+".number_of_threads",
+		"must not be less than number_of_cores (%d)", cpu.GetNumberOfCores())
+	v.check(cpu.GetMinGhz() > 0, field+".min_ghz", "must be positive")
+	v.check(cpu.GetMaxGhz() >= cpu.GetMinGhz(), field+".max_ghz", "must not be less than min_ghz (%g)", cpu.GetMinGhz())
+}
+
+func (v *validator) validateGPU(field string, gpu *pb.GPU) {
+	if gpu == nil {
+		v.add(field, "is required")
+		return
+	}
+
+	v.check(strings.TrimSpace(gpu.GetBrand()) != "", field+".brand", "must not be empty")
+	v.check(strings.TrimSpace(gpu.GetName()) != "", field+".name", "must not be empty")
+	v.check(gpu.GetMinGhz() > 0, field+".min_ghz", "must be positive")
+	v.check(gpu.GetMaxGhz() >= gpu.GetMinGhz(), field+".max_ghz", "must not be less than min_ghz (%g)", gpu.GetMinGhz())
+	v.validateMemory(field+".memory", gpu.GetMemory())
+}
+
+func (v *validator) validateMemory(field string, memory *pb.Memory) {
+	if memory == nil {
+		v.add(field, "is required")
+		return
+	}
+
+	v.check(memory.GetValue() > 0, field+".value", "must be positive")
+	v.check(memory.GetUnit() != pb.Memory_UNKNOWN, field+".unit", "must be specified")
+}
+
+func (v *validator) validateStorage(field string, storage *pb.Storage) {
+	if storage == nil {
+		v.add(field, "is required")
+		return
+	}
+
+	v.check(storage.GetDriver() != pb.Storage_UNKNOWN, field+".driver", "must be specified")
+	v.validateMemory(field+".memory", storage.GetMemory())
+}
+
+func (v *validator) validateScreen(field string, screen *pb.Screen) {
+	if screen == nil {
+		v.add(field, "is required")
+		return
+	}
+
+	v.check(screen.GetSizeInch() > 0, field+".size_inch", "must be positive")
+	v.check(screen.GetPanel() != pb.Screen_UNKNOWN, field+".panel", "must be specified")
+
+	resolution := screen.GetResolution()
+	if resolution == nil {
+		v.add(field+".resolution", "is required")
+		return
+	}
+
+	v.check(resolution.GetWidth() > 0, field+".resolution.width", "must be positive")
+	v.check(resolution.GetHeight() > 0, field+".resolution.height", "must be positive")
+}
+
+func (v *validator) validateKeyboard(field string, keyboard *pb.Keyboard) {
+	if keyboard == nil {
+		v.add(field, "is required")
+		return
+	}
+
+	v.check(keyboard.GetLayout() != pb.Keyboard_UNKNOWN, field+".layout", "must be specified")
+}
@@ -0,0 +1,146 @@
+package validation
+
+import (
+	"github.com/Adetunjii/go-grpc/pb"
+	"github.com/Adetunjii/go-grpc/sample"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestValidateLaptop(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		modify func(laptop *pb.Laptop)
+		fields []string
+	}{
+		{
+			name:   "valid",
+			modify: func(laptop *pb.Laptop) {},
+		},
+		{
+			name: "empty_brand_and_name",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Brand = " "
+				laptop.Name = ""
+			},
+			fields: []string{"brand", "name"},
+		},
+		{
+			name:   "negative_price",
+			modify: func(laptop *pb.Laptop) { laptop.PriceUsd = -1 },
+			fields: []string{"price_usd"},
+		},
+		{
+			name: "cpu_min_ghz_above_max_ghz",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Cpu.MinGhz = 3.5
+				laptop.Cpu.MaxGhz = 2.5
+			},
+			fields: []string{"cpu.max_ghz"},
+		},
+		{
+			name: "fewer_threads_than_cores",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Cpu.NumberOfCores = 8
+				laptop.Cpu.NumberOfThreads = 4
+			},
+			fields: []string{"cpu.number_of_threads"},
+		},
+		{
+			name:   "missing_cpu",
+			modify: func(laptop *pb.Laptop) { laptop.Cpu = nil },
+			fields: []string{"cpu"},
+		},
+		{
+			name:   "unknown_ram_unit",
+			modify: func(laptop *pb.Laptop) { laptop.Ram.Unit = pb.Memory_UNKNOWN },
+			fields: []string{"ram.unit"},
+		},
+		{
+			name: "invalid_gpu",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Gpus[0].Name = ""
+				laptop.Gpus[0].Memory.Value = 0
+			},
+			fields: []string{"gpus[0].name", "gpus[0].memory.value"},
+		},
+		{
+			name:   "unknown_storage_driver",
+			modify: func(laptop *pb.Laptop) { laptop.Storages[1].Driver = pb.Storage_UNKNOWN },
+			fields: []string{"storages[1].driver"},
+		},
+		{
+			name:   "no_storage",
+			modify: func(laptop *pb.Laptop) { laptop.Storages = nil },
+			fields: []string{"storages"},
+		},
+		{
+			name: "zero_screen_resolution",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Screen.Resolution.Width = 0
+				laptop.Screen.Resolution.Height = 0
+			},
+			fields: []string{"screen.resolution.width", "screen.resolution.height"},
+		},
+		{
+			name:   "unknown_screen_panel",
+			modify: func(laptop *pb.Laptop) { laptop.Screen.Panel = pb.Screen_UNKNOWN },
+			fields: []string{"screen.panel"},
+		},
+		{
+			name:   "unknown_keyboard_layout",
+			modify: func(laptop *pb.Laptop) { laptop.Keyboard.Layout = pb.Keyboard_UNKNOWN },
+			fields: []string{"keyboard.layout"},
+		},
+		{
+			name:   "missing_weight",
+			modify: func(laptop *pb.Laptop) { laptop.Weight = nil },
+			fields: []string{"weight"},
+		},
+		{
+			name:   "negative_weight_lb",
+			modify: func(laptop *pb.Laptop) { laptop.Weight = &pb.Laptop_WeightLb{WeightLb: -2} },
+			fields: []string{"weight_lb"},
+		},
+		{
+			name: "every_violation_reported",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Brand = ""
+				laptop.PriceUsd = -10
+				laptop.Screen = nil
+				laptop.Keyboard = nil
+			},
+			fields: []string{"brand", "price_usd", "screen", "keyboard"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			laptop := sample.NewLaptop()
+			tc.modify(laptop)
+
+			err := ValidateLaptop(laptop)
+			if len(tc.fields) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var violations Errors
+			require.ErrorAs(t, err, &violations)
+			require.Equal(t, tc.fields, violations.Fields())
+		})
+	}
+}
+
+func TestValidateLaptopNil(t *testing.T) {
+	t.Parallel()
+
+	var violations Errors
+	require.ErrorAs(t, ValidateLaptop(nil), &violations)
+	require.Equal(t, []string{"laptop"}, violations.Fields())
+}